@@ -0,0 +1,341 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/HabibPro1999/shiplog/internal/archive"
+	"github.com/HabibPro1999/shiplog/internal/parser"
+	"github.com/HabibPro1999/shiplog/internal/pricing"
+	"github.com/HabibPro1999/shiplog/internal/redact"
+	"github.com/HabibPro1999/shiplog/internal/render"
+	"github.com/HabibPro1999/shiplog/internal/session"
+	pflag "github.com/spf13/pflag"
+)
+
+// sessionsDir is the directory of the session pages within a batch export.
+const sessionsDir = "sessions"
+
+// runExport implements `shiplog export`: a static site with one HTML page per
+// session and an index.html listing them. A manifest in the output directory
+// lets later runs skip sessions whose transcripts and render options have not
+// changed, and remove the pages of sessions whose transcripts are gone.
+func runExport(args []string) {
+	var (
+		showAll   bool
+		force     bool
+		reindex   bool
+		output    string
+		project   string
+		since     string
+		until     string
+		thinking  string
+		branch    string
+		prices    string
+		theme     string
+		tmplDir   string
+		redactOn  bool
+		redactCfg string
+		anonymize bool
+		anonCfg   string
+	)
+
+	fs := pflag.NewFlagSet("export", pflag.ExitOnError)
+	fs.BoolVarP(&showAll, "all", "a", false, "Export sessions of all projects (ignore project context)")
+	fs.StringVarP(&output, "output", "o", "shiplog-site", "Output directory")
+	fs.StringVar(&project, "project", "", "Only sessions whose project path contains this text")
+	fs.StringVar(&since, "since", "", "Only sessions starting on or after this date (YYYY-MM-DD)")
+	fs.StringVar(&until, "until", "", "Only sessions starting on or before this date (YYYY-MM-DD)")
+	fs.StringVar(&thinking, "thinking", render.ThinkingHide, "Thinking blocks: hide, collapsed or show")
+	fs.StringVar(&branch, "branch", parser.BranchLatest, "Conversation branch: latest or all")
+	fs.StringVar(&prices, "pricing", "", "Pricing table JSON (default: "+pricing.DefaultPath()+" if present)")
+	fs.StringVar(&theme, "theme", render.ThemeAuto, "HTML theme: "+strings.Join(render.Themes(), ", "))
+	fs.StringVar(&tmplDir, "template", "", "HTML template directory with chat.html and/or .css files")
+	fs.BoolVar(&redactOn, "redact", false, "Replace secrets and email addresses with placeholders")
+	fs.StringVar(&redactCfg, "redact-config", "", "Redaction patterns JSON (default: "+redact.DefaultPath()+" if present)")
+	fs.BoolVar(&anonymize, "anonymize", false, "Replace home directory, user name, host name and internal names")
+	fs.StringVar(&anonCfg, "anonymize-config", "", "Internal names JSON (default: "+redact.AnonymizePath()+" if present)")
+	fs.BoolVar(&force, "force", false, "Render every session, even if unchanged since the last export")
+	fs.BoolVar(&reindex, "reindex", false, "Rebuild the session index instead of reusing cached headers")
+	fs.Parse(args)
+
+	switch thinking {
+	case render.ThinkingHide, render.ThinkingCollapsed, render.ThinkingShow:
+	default:
+		fmt.Fprintf(os.Stderr, "  Error: invalid --thinking value %q (want hide, collapsed or show)\n", thinking)
+		os.Exit(1)
+	}
+	// An entry UUID only exists in one session.
+	if branch != parser.BranchLatest && branch != parser.BranchAll {
+		fmt.Fprintf(os.Stderr, "  Error: invalid --branch value %q (want latest or all)\n", branch)
+		os.Exit(1)
+	}
+	checkTheme(theme, tmplDir)
+	from, err := parseDay(since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error: invalid --since date: %v\n", err)
+		os.Exit(1)
+	}
+	to, err := parseDay(until)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error: invalid --until date: %v\n", err)
+		os.Exit(1)
+	}
+	priceTable, err := pricing.Load(prices)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error loading pricing: %v\n", err)
+		os.Exit(1)
+	}
+	redactor := loadRedactor(redactOn, redactCfg)
+	anonymizer := loadAnonymizer(anonymize, anonCfg)
+
+	claudeDir := claudeProjectsDir()
+	projectFilter, scopeLabel := projectScope(claudeDir, showAll)
+
+	fmt.Printf("  Scanning sessions (%s)...\n", scopeLabel)
+	sessions, err := findSessions(claudeDir, projectFilter, loadIndex(reindex))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error scanning sessions: %v\n", err)
+		os.Exit(1)
+	}
+
+	var selected []session.SessionInfo
+	for _, s := range sessions {
+		if project != "" && !strings.Contains(strings.ToLower(s.Project), strings.ToLower(project)) {
+			continue
+		}
+		if !withinDays(s, from, to) {
+			continue
+		}
+		selected = append(selected, s)
+	}
+
+	manifest, err := archive.Load(output)
+	if err != nil {
+		if !force {
+			fmt.Fprintf(os.Stderr, "  Error reading manifest: %v (use --force to rebuild it)\n", err)
+			os.Exit(1)
+		}
+		manifest = archive.New()
+	}
+	if len(selected) == 0 && len(manifest.Sessions) == 0 {
+		fmt.Println("  No sessions to export.")
+		return
+	}
+	fmt.Printf("  Exporting %d sessions to %s...\n", len(selected), output)
+
+	if err := os.MkdirAll(filepath.Join(output, sessionsDir), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "  Error creating output directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := render.Options{Thinking: thinking, Theme: theme, Template: tmplDir, IndexURL: "../index.html"}
+	optionsKey, err := exportOptionsKey(opts, branch, priceTable, redactor, anonymizer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error reading --template: %v\n", err)
+		os.Exit(1)
+	}
+
+	seen := make(map[string]bool)
+	rendered, unchanged := 0, 0
+	for _, s := range selected {
+		seen[s.SessionID] = true
+		files := parser.SessionFiles(s.FilePath)
+		sources, err := archive.Stat(files)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  Skipping %s: %v\n", s.SessionID, err)
+			continue
+		}
+		for i := range sources {
+			sources[i].Path = manifestPath(claudeDir, sources[i].Path, anonymizer)
+		}
+		prev, known := manifest.Sessions[s.SessionID]
+		current := known && !force && prev.Shiplog == version && prev.Options == optionsKey
+		if current && archive.SameSources(prev.Sources, sources) {
+			unchanged++
+			continue
+		}
+		hash, err := archive.HashFiles(files)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  Skipping %s: %v\n", s.SessionID, err)
+			continue
+		}
+		if current && prev.Hash == hash {
+			// Touched but not modified.
+			prev.Sources = sources
+			manifest.Sessions[s.SessionID] = prev
+			unchanged++
+			continue
+		}
+
+		data, row, err := exportSession(s, branch, priceTable, redactor, anonymizer, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  Skipping %s: %v\n", s.SessionID, err)
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(output, filepath.FromSlash(row.URL)), data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "  Error writing file: %v\n", err)
+			os.Exit(1)
+		}
+		manifest.Sessions[s.SessionID] = archive.Entry{
+			Sources: sources,
+			Hash:    hash,
+			Shiplog: version,
+			Options: optionsKey,
+			Page:    row.URL,
+			Index:   row,
+		}
+		rendered++
+	}
+
+	// Pages of sessions outside this run's filters stay in the archive
+	// unless their transcript has been deleted.
+	removed := 0
+	for id, e := range manifest.Sessions {
+		if seen[id] || transcriptExists(claudeDir, id) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(output, filepath.FromSlash(e.Page))); err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "  Error removing page: %v\n", err)
+			os.Exit(1)
+		}
+		delete(manifest.Sessions, id)
+		removed++
+	}
+
+	var rows []render.IndexSession
+	for _, e := range manifest.Sessions {
+		rows = append(rows, e.Index)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Start != rows[j].Start {
+			return rows[i].Start > rows[j].Start
+		}
+		return rows[i].URL < rows[j].URL
+	})
+
+	index, err := render.GenerateIndex(rows, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error generating index: %v\n", err)
+		os.Exit(1)
+	}
+	indexPath := filepath.Join(output, "index.html")
+	if err := os.WriteFile(indexPath, index, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "  Error writing file: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := manifest.Save(output); err != nil {
+		fmt.Fprintf(os.Stderr, "  Error writing manifest: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("  %d rendered, %d unchanged, %d removed\n", rendered, unchanged, removed)
+	if redactor != nil && rendered > 0 {
+		reportRedactions(redactor.Summary())
+	}
+	fmt.Printf("  Index written to %s\n", indexPath)
+	fmt.Println("  Done.")
+}
+
+// manifestPath returns how a transcript is recorded in the manifest, which
+// is published with the pages: relative to the Claude projects directory,
+// so that it does not reveal the home directory, and anonymized like the
+// pages.
+func manifestPath(claudeDir, path string, anonymizer *redact.Anonymizer) string {
+	if rel, err := filepath.Rel(claudeDir, path); err == nil {
+		path = filepath.ToSlash(rel)
+	}
+	if anonymizer != nil {
+		path = anonymizer.String(path)
+	}
+	return path
+}
+
+// transcriptExists reports whether the transcript of a session is still in
+// some project directory. Session ids are unique across projects, and the
+// manifest's paths may be anonymized, so the transcript is looked up by id.
+// Errors count as existing, so that pages are only removed for certain.
+func transcriptExists(claudeDir, sessionID string) bool {
+	matches, err := filepath.Glob(filepath.Join(claudeDir, "*", sessionID+".jsonl"))
+	return err != nil || len(matches) > 0
+}
+
+// exportOptionsKey fingerprints everything besides the transcript that
+// shapes a session page: the render options, the branch, the prices, the
+// redaction and anonymization rules, and the contents of the template
+// directory.
+func exportOptionsKey(opts render.Options, branch string, priceTable pricing.Table, redactor *redact.Redactor, anonymizer *redact.Anonymizer) (string, error) {
+	key := struct {
+		Options   render.Options
+		Branch    string
+		Pricing   pricing.Table
+		Redact    []string
+		Anonymize []string
+		Template  string
+	}{Options: opts, Branch: branch, Pricing: priceTable}
+	if redactor != nil {
+		key.Redact = redactor.Patterns()
+	}
+	if anonymizer != nil {
+		key.Anonymize = anonymizer.Rules()
+	}
+	if opts.Template != "" {
+		files, err := filepath.Glob(filepath.Join(opts.Template, "*.*"))
+		if err != nil {
+			return "", err
+		}
+		sort.Strings(files)
+		if key.Template, err = archive.HashFiles(files); err != nil {
+			return "", err
+		}
+	}
+	return archive.Fingerprint(key)
+}
+
+// exportSession renders one session's page for a batch export and returns it
+// with the session's index row. The row's URL is the page's path relative to
+// the output directory.
+func exportSession(s session.SessionInfo, branch string, priceTable pricing.Table, redactor *redact.Redactor, anonymizer *redact.Anonymizer, opts render.Options) ([]byte, render.IndexSession, error) {
+	transcript, err := parser.ParseSession(s.FilePath)
+	if transcript == nil {
+		return nil, render.IndexSession{}, err
+	}
+	if err != nil {
+		warnPartialRead(s.SessionID, err)
+	}
+	messages, err := parser.BuildConversation(transcript.Entries, branch)
+	if err != nil {
+		return nil, render.IndexSession{}, err
+	}
+	meta := parser.ExtractMeta(transcript.Entries)
+	project := s.Project
+	if redactor != nil {
+		messages = redactSession(redactor, messages, &meta)
+	}
+	if anonymizer != nil {
+		messages, project = anonymizeSession(anonymizer, messages, &meta, project)
+	}
+	priceTable.Estimate(&meta)
+
+	data, err := render.Generate(messages, meta, project, opts)
+	if err != nil {
+		return nil, render.IndexSession{}, err
+	}
+	userCount, assistantCount := render.CountMessages(messages)
+	row := render.IndexSession{
+		Title:          meta.Title,
+		Project:        project,
+		DateRange:      meta.DateRange,
+		Start:          meta.Start,
+		Model:          meta.Model,
+		UserCount:      userCount,
+		AssistantCount: assistantCount,
+		URL:            sessionsDir + "/" + s.SessionID + ".html",
+	}
+	return data, row, nil
+}
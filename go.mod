@@ -3,3 +3,14 @@ module github.com/HabibPro1999/shiplog
 go 1.24.0
 
 require github.com/spf13/pflag v1.0.10
+
+require (
+	github.com/alecthomas/chroma/v2 v2.24.1
+	github.com/yuin/goldmark v1.8.2
+	golang.org/x/term v0.40.0
+)
+
+require (
+	github.com/dlclark/regexp2 v1.12.0 // indirect
+	golang.org/x/sys v0.41.0 // indirect
+)
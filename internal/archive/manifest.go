@@ -0,0 +1,142 @@
+// Package archive tracks the sessions of a batch export so that later
+// exports into the same directory only render what changed.
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/HabibPro1999/shiplog/internal/render"
+)
+
+// ManifestName is the manifest's file name within the output directory.
+const ManifestName = "shiplog-manifest.json"
+
+// manifestVersion is bumped when the manifest format changes; manifests of
+// other versions are discarded, re-rendering every session.
+const manifestVersion = 2
+
+// Manifest records, per session id, what each page was rendered from.
+type Manifest struct {
+	Version  int              `json:"version"`
+	Sessions map[string]Entry `json:"sessions"`
+}
+
+// Entry describes the page of one session.
+type Entry struct {
+	Sources []Source            `json:"sources"` // the transcript followed by its subagent transcripts
+	Hash    string              `json:"sha256"`  // of the sources' contents
+	Shiplog string              `json:"shiplog_version"`
+	Options string              `json:"options"` // fingerprint of the render options
+	Page    string              `json:"page"`    // relative to the output directory
+	Index   render.IndexSession `json:"index"`   // the session's row in index.html
+}
+
+// Source is a transcript file as it was when its session was rendered.
+// The manifest is published with the pages, so Path is not the absolute
+// path: the caller records it relative to the Claude projects directory and
+// anonymized like the pages.
+type Source struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// New returns an empty manifest.
+func New() *Manifest {
+	return &Manifest{Version: manifestVersion, Sessions: make(map[string]Entry)}
+}
+
+// Load reads the manifest of an output directory. A missing manifest, or one
+// written by an incompatible version, yields an empty manifest.
+func Load(dir string) (*Manifest, error) {
+	m := New()
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved Manifest
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("%s: %w", ManifestName, err)
+	}
+	if saved.Version == manifestVersion && saved.Sessions != nil {
+		m.Sessions = saved.Sessions
+	}
+	return m, nil
+}
+
+// Save writes the manifest to an output directory.
+func (m *Manifest) Save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestName), append(data, '\n'), 0644)
+}
+
+// Stat returns the current size and modification time of files.
+func Stat(files []string) ([]Source, error) {
+	sources := make([]Source, 0, len(files))
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, Source{Path: f, Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return sources, nil
+}
+
+// SameSources reports whether two stats of a session's files match.
+func SameSources(a, b []Source) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Path != b[i].Path || a[i].Size != b[i].Size || !a[i].ModTime.Equal(b[i].ModTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// HashFiles returns the hex SHA-256 of the concatenated contents of files,
+// each preceded by its name so that moving content between files counts as
+// a change.
+func HashFiles(files []string) (string, error) {
+	h := sha256.New()
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00", filepath.Base(name))
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Fingerprint returns the hex SHA-256 of v's JSON encoding, for comparing
+// render options between runs.
+func Fingerprint(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
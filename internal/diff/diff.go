@@ -0,0 +1,139 @@
+// Package diff computes line-based diffs for displaying the edits made by
+// tool calls.
+package diff
+
+import "strings"
+
+// Op identifies what happened to a line between the old and new text.
+type Op byte
+
+const (
+	Equal  Op = ' '
+	Insert Op = '+'
+	Delete Op = '-'
+)
+
+// Line is a single line of a line-based diff.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// maxCells bounds the size of the LCS table. Inputs larger than this are
+// reported as a full replacement instead of a minimal diff.
+const maxCells = 4_000_000
+
+// Lines computes a line-based diff turning oldText into newText.
+// Deletions are listed before insertions within each changed region.
+func Lines(oldText, newText string) []Line {
+	a := splitLines(oldText)
+	b := splitLines(newText)
+
+	// Trim the common prefix and suffix so the LCS table only covers the
+	// region that actually changed.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var out []Line
+	for _, s := range a[:prefix] {
+		out = append(out, Line{Equal, s})
+	}
+	out = append(out, middle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, s := range a[len(a)-suffix:] {
+		out = append(out, Line{Equal, s})
+	}
+	return out
+}
+
+// middle diffs the changed region between a and b using a longest common
+// subsequence table.
+func middle(a, b []string) []Line {
+	if len(a)*len(b) > maxCells || len(a) == 0 || len(b) == 0 {
+		return replace(a, b)
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out, ins []Line
+	flush := func() {
+		out = append(out, ins...)
+		ins = ins[:0]
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			flush()
+			out = append(out, Line{Equal, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, Line{Delete, a[i]})
+			i++
+		default:
+			ins = append(ins, Line{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, Line{Delete, a[i]})
+	}
+	flush()
+	for ; j < len(b); j++ {
+		out = append(out, Line{Insert, b[j]})
+	}
+	return out
+}
+
+// replace reports every line of a as deleted and every line of b as inserted.
+func replace(a, b []string) []Line {
+	out := make([]Line, 0, len(a)+len(b))
+	for _, s := range a {
+		out = append(out, Line{Delete, s})
+	}
+	for _, s := range b {
+		out = append(out, Line{Insert, s})
+	}
+	return out
+}
+
+// Stats counts the inserted and deleted lines in a diff.
+func Stats(lines []Line) (added, removed int) {
+	for _, l := range lines {
+		switch l.Op {
+		case Insert:
+			added++
+		case Delete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// splitLines splits s into lines, ignoring a single trailing newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,69 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string // one line per diff line, prefixed with its op
+	}{
+		{"identical", "a\nb\n", "a\nb\n", " a\n b"},
+		{"write", "", "a\nb", "+a\n+b"},
+		{"delete all", "a\nb", "", "-a\n-b"},
+		{"trailing newline ignored", "a\n", "a", " a"},
+		{"common prefix and suffix", "a\nb\nc\nd", "a\nx\nc\nd", " a\n-b\n+x\n c\n d"},
+		{"insertion only", "a\nc", "a\nb\nc", " a\n+b\n c"},
+		{"deletion only", "a\nb\nc", "a\nc", " a\n-b\n c"},
+		{"prefix overlaps suffix", "a\na", "a\na\na", " a\n a\n+a"},
+		{"lcs inside the changed region", "x\na\ny", "z\na\nw", "-x\n+z\n a\n-y\n+w"},
+		{"deletions before insertions", "a\nb", "c\nd", "-a\n-b\n+c\n+d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := format(Lines(tt.old, tt.new)); got != tt.want {
+				t.Errorf("Lines(%q, %q) =\n%s\nwant\n%s", tt.old, tt.new, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinesOverMaxCells(t *testing.T) {
+	// 2001 changed lines on each side exceed maxCells, so the changed region
+	// is reported as a replacement even though one line is common to both.
+	const n = 2001
+	var a, b []string
+	for i := 0; i < n; i++ {
+		a = append(a, "old")
+		b = append(b, "new")
+	}
+	a[n/2], b[n/2] = "same", "same"
+	got := Lines("head\n"+strings.Join(a, "\n")+"\ntail", "head\n"+strings.Join(b, "\n")+"\ntail")
+
+	if len(got) != 2*n+2 {
+		t.Fatalf("len(Lines) = %d, want %d", len(got), 2*n+2)
+	}
+	if got[0] != (Line{Equal, "head"}) || got[len(got)-1] != (Line{Equal, "tail"}) {
+		t.Errorf("Lines kept %v … %v, want the common head and tail", got[0], got[len(got)-1])
+	}
+	if added, removed := Stats(got); added != n || removed != n {
+		t.Errorf("Stats(Lines) = +%d −%d, want +%d −%d", added, removed, n, n)
+	}
+	for i, l := range got[1 : n+1] {
+		if l.Op != Delete {
+			t.Fatalf("line %d = %v, want every deletion before the insertions", i+1, l)
+		}
+	}
+}
+
+// format renders a diff one line per entry, prefixed with its op.
+func format(lines []Line) string {
+	var out []string
+	for _, l := range lines {
+		out = append(out, string(l.Op)+l.Text)
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,62 @@
+package parser
+
+// Activity counts the prompts, replies and tool calls of one model in a
+// transcript.
+type Activity struct {
+	Model     string
+	Prompts   int            // user prompts, attributed to the model that answered them
+	Replies   int            // assistant responses containing text
+	ToolCalls map[string]int // tool name -> calls, including those made by subagents
+}
+
+// CountActivity tallies a transcript's activity per model, in order of first
+// use. Prompts and replies are counted on the main thread only.
+func CountActivity(entries []Entry) []Activity {
+	var activity []Activity
+	index := make(map[string]int)
+	get := func(model string) *Activity {
+		i, ok := index[model]
+		if !ok {
+			i = len(activity)
+			index[model] = i
+			activity = append(activity, Activity{Model: model, ToolCalls: make(map[string]int)})
+		}
+		return &activity[i]
+	}
+
+	replied := make(map[string]bool)
+	unanswered := 0
+	for _, e := range entries {
+		switch e.Type {
+		case EntryUser:
+			if !e.IsSidechain && extractUserMessage(e) != nil {
+				unanswered++
+			}
+		case EntryAssistant:
+			if e.Message == nil || e.Message.Model == "" || e.Message.Model == "<synthetic>" {
+				continue
+			}
+			a := get(e.Message.Model)
+			for _, b := range e.Message.Content {
+				switch b.Type {
+				case BlockToolUse:
+					a.ToolCalls[b.Name]++
+				case BlockText:
+					id := e.Message.ID
+					if e.IsSidechain || b.Text == "" || (id != "" && replied[id]) {
+						continue
+					}
+					if id != "" {
+						replied[id] = true
+					}
+					a.Replies++
+				}
+			}
+			if !e.IsSidechain {
+				a.Prompts += unanswered
+				unanswered = 0
+			}
+		}
+	}
+	return activity
+}
@@ -17,9 +17,9 @@ var systemPrefixes = []string{
 	"This session is being continued from a previous conversation",
 }
 
-// isSystemContent returns true if the string is system/internal content
+// IsSystemContent returns true if the string is system/internal content
 // that should be filtered out of the chat display.
-func isSystemContent(s string) bool {
+func IsSystemContent(s string) bool {
 	trimmed := strings.TrimSpace(s)
 	for _, prefix := range systemPrefixes {
 		if strings.HasPrefix(trimmed, prefix) {
@@ -32,32 +32,21 @@ func isSystemContent(s string) bool {
 	return false
 }
 
-// isOnlyToolResults returns true if all blocks in the content list are
-// tool_result type (no meaningful text or image content).
-func isOnlyToolResults(blocks []any) bool {
-	if len(blocks) == 0 {
-		return true
-	}
-	hasNonTool := false
-	for _, block := range blocks {
-		switch b := block.(type) {
-		case map[string]any:
-			switch b["type"] {
-			case "text":
-				text := strings.TrimSpace(asString(b["text"]))
-				if text != "" && !isSystemContent(text) {
-					hasNonTool = true
-				}
-			case "image":
-				hasNonTool = true
-			}
-		case string:
-			if strings.TrimSpace(b) != "" && !isSystemContent(b) {
-				hasNonTool = true
+// isOnlyToolResults returns true if the content has no meaningful text or
+// image blocks (tool results, empty text and system content only).
+func isOnlyToolResults(blocks Content) bool {
+	for _, b := range blocks {
+		switch b.Type {
+		case BlockText:
+			text := strings.TrimSpace(b.Text)
+			if text != "" && !IsSystemContent(text) {
+				return false
 			}
+		case BlockImage:
+			return false
 		}
 	}
-	return !hasNonTool
+	return true
 }
 
 // toolDisplayNames maps internal tool names to human-readable labels.
@@ -109,11 +98,3 @@ func titleCase(s string) string {
 		return r
 	}, s)
 }
-
-// asString safely extracts a string from an any value.
-func asString(v any) string {
-	if s, ok := v.(string); ok {
-		return s
-	}
-	return ""
-}
@@ -3,95 +3,152 @@ package parser
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"os"
 	"strings"
 	"time"
 )
 
-// ParseFile reads a JSONL file and returns a slice of parsed entries.
-// Malformed lines are silently skipped.
-func ParseFile(path string) ([]map[string]any, error) {
+// ParseFile reads a JSONL file and decodes each line into an Entry.
+// Malformed lines are skipped and counted; entries and content blocks of
+// unrecognised types are kept and reported in Transcript.Unknown. Fields
+// whose value has the wrong type are left empty and reported in
+// Transcript.Mistyped, keeping the rest of their entry.
+func ParseFile(path string) (*Transcript, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	var entries []map[string]any
+	t := &Transcript{}
 	scanner := bufio.NewScanner(f)
 	scanner.Buffer(make([]byte, 0, 1024*1024), 10*1024*1024) // 10 MB max line
+	lineNo := 0
 	for scanner.Scan() {
+		lineNo++
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
 		}
-		var entry map[string]any
-		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		entry, mistyped, ok := decodeEntry([]byte(line))
+		if !ok {
+			t.Malformed++
 			continue
 		}
-		entries = append(entries, entry)
+		if entry.Message != nil {
+			for _, f := range mistypedBlocks(entry.Message.Content) {
+				mistyped = append(mistyped, "message."+f)
+			}
+		}
+		for _, f := range mistyped {
+			t.Mistyped = append(t.Mistyped, MistypedField{Field: f, File: path, Line: lineNo})
+		}
+		if !knownEntryTypes[entry.Type] {
+			t.Unknown = append(t.Unknown, UnknownType{Kind: "entry", Type: entry.Type, File: path, Line: lineNo})
+		} else if entry.Message != nil {
+			for _, bt := range unknownBlocks(entry.Message.Content) {
+				t.Unknown = append(t.Unknown, UnknownType{Kind: "block", Type: bt, File: path, Line: lineNo})
+			}
+		}
+		t.Entries = append(t.Entries, entry)
 	}
 	if err := scanner.Err(); err != nil {
-		return entries, err
+		return t, err
+	}
+	return t, nil
+}
+
+// FirstPrompts returns the first n user prompts of a transcript, reading
+// only as far as it needs to. Prompts are taken in file order without
+// building the conversation tree, so one from an abandoned branch may be
+// included. Prompts read before an error are returned with it.
+func FirstPrompts(path string, n int) ([]Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var prompts []Message
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 10*1024*1024) // 10 MB max line
+	for len(prompts) < n && scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		entry, _, ok := decodeEntry([]byte(line))
+		if !ok || entry.Type != EntryUser || entry.IsSidechain {
+			continue
+		}
+		if msg := extractUserMessage(entry); msg != nil {
+			prompts = append(prompts, *msg)
+		}
 	}
-	return entries, nil
+	return prompts, scanner.Err()
+}
+
+// decodeEntry decodes a single JSONL line. Lines whose type is unknown are
+// kept with their raw JSON even if their fields do not fit the Entry schema.
+// Entries of known types with a field of the wrong type are kept with that
+// field empty, and the field is returned.
+func decodeEntry(line []byte) (entry Entry, mistyped []string, ok bool) {
+	err := json.Unmarshal(line, &entry)
+	if err == nil {
+		if !knownEntryTypes[entry.Type] {
+			entry.Raw = json.RawMessage(line)
+		}
+		return entry, nil, true
+	}
+	var head struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(line, &head) != nil {
+		return Entry{}, nil, false
+	}
+	if !knownEntryTypes[head.Type] {
+		return Entry{Type: head.Type, Raw: json.RawMessage(line)}, nil, true
+	}
+	// The decoder skips values of the wrong type and fills in the rest,
+	// reporting the first one.
+	var te *json.UnmarshalTypeError
+	if !errors.As(err, &te) {
+		return Entry{}, nil, false
+	}
+	return entry, []string{te.Field}, true
 }
 
 // extractUserMessage extracts text and images from a user entry.
 // Returns nil if the message should be skipped (system content, only tool results, empty).
-func extractUserMessage(entry map[string]any) *Message {
-	msg, ok := entry["message"].(map[string]any)
-	if !ok {
+func extractUserMessage(entry Entry) *Message {
+	if entry.Message == nil || isOnlyToolResults(entry.Message.Content) {
 		return nil
 	}
-	content := msg["content"]
-	timestamp := asString(entry["timestamp"])
 
 	var texts []string
 	var images []Image
 
-	switch c := content.(type) {
-	case string:
-		if strings.TrimSpace(c) == "" || isSystemContent(c) {
-			return nil
-		}
-		texts = append(texts, c)
-
-	case []any:
-		if isOnlyToolResults(c) {
-			return nil
-		}
-		for _, block := range c {
-			bm, ok := block.(map[string]any)
-			if !ok {
+	for _, block := range entry.Message.Content {
+		switch block.Type {
+		case BlockText:
+			text := strings.TrimSpace(block.Text)
+			if text != "" && !IsSystemContent(text) {
+				texts = append(texts, text)
+			}
+		case BlockImage:
+			if block.Source == nil || block.Source.Type != "base64" {
 				continue
 			}
-			switch asString(bm["type"]) {
-			case "text":
-				text := strings.TrimSpace(asString(bm["text"]))
-				if text != "" && !isSystemContent(text) {
-					texts = append(texts, text)
-				}
-			case "image":
-				source, ok := bm["source"].(map[string]any)
-				if !ok {
-					continue
-				}
-				if asString(source["type"]) == "base64" {
-					mediaType := asString(source["media_type"])
-					if mediaType == "" {
-						mediaType = "image/png"
-					}
-					images = append(images, Image{
-						MediaType: mediaType,
-						Data:      asString(source["data"]),
-					})
-				}
+			mediaType := block.Source.MediaType
+			if mediaType == "" {
+				mediaType = "image/png"
 			}
+			images = append(images, Image{
+				MediaType: mediaType,
+				Data:      block.Source.Data,
+			})
 		}
-
-	default:
-		return nil
 	}
 
 	if len(texts) == 0 && len(images) == 0 {
@@ -101,137 +158,270 @@ func extractUserMessage(entry map[string]any) *Message {
 		Role:      "user",
 		Texts:     texts,
 		Images:    images,
-		Timestamp: timestamp,
+		Timestamp: entry.Timestamp,
+		UUID:      entry.UUID,
 	}
 }
 
-// extractAssistantMessage extracts text blocks and tool_use names from an assistant entry.
-// Thinking blocks are skipped entirely.
+// extractAssistantMessage extracts text blocks and tool calls from an assistant entry.
+// Each tool_use is paired with its tool_result from results.
 // Returns nil if nothing meaningful was found.
-func extractAssistantMessage(entry map[string]any) *Message {
-	msg, ok := entry["message"].(map[string]any)
-	if !ok {
-		return nil
-	}
-	content, ok := msg["content"].([]any)
-	if !ok {
+func extractAssistantMessage(entry Entry, results map[string]toolResult) *Message {
+	if entry.Message == nil {
 		return nil
 	}
-	timestamp := asString(entry["timestamp"])
 
 	var texts []string
-	var toolUses []string
+	var thinking []string
+	var tools []ToolCall
 
-	for _, block := range content {
-		bm, ok := block.(map[string]any)
-		if !ok {
-			continue
-		}
-		blockType := asString(bm["type"])
-		switch blockType {
-		case "text":
-			text := strings.TrimSpace(asString(bm["text"]))
+	for _, block := range entry.Message.Content {
+		switch block.Type {
+		case BlockText:
+			text := strings.TrimSpace(block.Text)
 			if text != "" {
 				texts = append(texts, text)
 			}
-		case "tool_use":
-			name := asString(bm["name"])
-			if name == "" {
-				name = "unknown"
+		case BlockToolUse:
+			call := newToolCall(block.ID, block.Name, block.Input)
+			if call.Name == "" {
+				call.Name = "unknown"
+			}
+			if res, ok := results[block.ID]; ok {
+				call.Result = resultText(res.block.Content)
+				call.IsError = res.block.IsError
+				call.HasResult = true
+				call.AgentID = res.agentID
+			}
+			tools = append(tools, call)
+		case BlockThinking:
+			text := strings.TrimSpace(block.Thinking)
+			if text != "" {
+				thinking = append(thinking, text)
 			}
-			toolUses = append(toolUses, name)
-			// "thinking" blocks are intentionally skipped
 		}
 	}
 
-	if len(texts) == 0 && len(toolUses) == 0 {
+	if len(texts) == 0 && len(tools) == 0 && len(thinking) == 0 {
 		return nil
 	}
 	return &Message{
 		Role:      "assistant",
 		Texts:     texts,
-		ToolUses:  toolUses,
-		Timestamp: timestamp,
+		Thinking:  thinking,
+		Tools:     tools,
+		Timestamp: entry.Timestamp,
+		UUID:      entry.UUID,
 	}
 }
 
 // BuildMessages iterates through parsed JSONL entries and produces a list of
 // Messages. Consecutive tool-only assistant messages are collapsed into a
 // single tool_group message. A tool_group is flushed whenever a user message
-// or an assistant message with text appears.
-func BuildMessages(entries []map[string]any) []Message {
+// or an assistant message with text appears. Tool calls made alongside text are
+// moved into the tool_group that follows the text.
+//
+// Thinking blocks are attached to the next assistant message with text. If the
+// turn ends without one, they are emitted as an assistant message that has
+// Thinking but no Texts.
+//
+// Sidechain entries are not part of the main conversation; each subagent
+// conversation is built separately and attached to the Task call that
+// launched it.
+func BuildMessages(entries []Entry) []Message {
+	mainEntries, chains := splitSidechains(entries)
+	return newBuilder(entries, chains).build(mainEntries)
+}
+
+// builder holds the transcript-wide lookups used while building messages.
+type builder struct {
+	chains   []*sidechain
+	results  map[string]toolResult
+	usage    map[string]Usage // final usage per API response
+	credited map[string]bool  // responses whose usage is already on a message
+}
+
+// newBuilder indexes the tool results and response usage of all entries.
+func newBuilder(entries []Entry, chains []*sidechain) *builder {
+	return &builder{
+		chains:   chains,
+		results:  collectToolResults(entries),
+		usage:    responseUsage(entries),
+		credited: make(map[string]bool),
+	}
+}
+
+// credit adds the usage of the responses with the given ids that are not
+// yet on a message to u, and marks them as credited. It returns nil if
+// there is no usage at all.
+func (b *builder) credit(u *Usage, ids ...string) *Usage {
+	for _, id := range ids {
+		r, ok := b.usage[id]
+		if id == "" || !ok || b.credited[id] {
+			continue
+		}
+		b.credited[id] = true
+		if u == nil {
+			u = &Usage{}
+		}
+		u.InputTokens += r.InputTokens
+		u.OutputTokens += r.OutputTokens
+		u.CacheCreationInputTokens += r.CacheCreationInputTokens
+		u.CacheReadInputTokens += r.CacheReadInputTokens
+	}
+	return u
+}
+
+// build implements BuildMessages for one conversation thread.
+func (b *builder) build(entries []Entry) []Message {
 	var messages []Message
-	var pendingTools []string
+	var pendingTools []ToolCall
+	var pendingThinking []string
+	var toolsUUID, thinkingUUID string // entries that started the pending tools and thinking
+	var thinkingTS string
+	var toolsUsage *Usage    // of the responses that made the pending tools
+	var thinkingIDs []string // responses of the pending thinking, not yet credited
 
 	flushTools := func() {
 		if len(pendingTools) == 0 {
 			return
 		}
 		messages = append(messages, Message{
-			Role:      "tool_group",
-			ToolNames: append([]string(nil), pendingTools...),
+			Role:  "tool_group",
+			Tools: append([]ToolCall(nil), pendingTools...),
+			UUID:  toolsUUID,
+			Usage: toolsUsage,
 		})
 		pendingTools = pendingTools[:0]
+		toolsUsage = nil
 	}
 
-	for _, entry := range entries {
-		entryType := asString(entry["type"])
+	flushThinking := func() {
+		if len(pendingThinking) == 0 {
+			return
+		}
+		messages = append(messages, Message{
+			Role:      "assistant",
+			Thinking:  pendingThinking,
+			Timestamp: thinkingTS,
+			UUID:      thinkingUUID,
+			Usage:     b.credit(nil, thinkingIDs...),
+		})
+		pendingThinking = nil
+		thinkingIDs = nil
+	}
+
+	// Thinking and tools are flushed as the other kind arrives, so at most
+	// one of them is pending and both keep their order in the transcript.
+	flushTurn := func() {
+		flushTools()
+		flushThinking()
+	}
 
-		switch entryType {
-		case "user":
+	for _, entry := range entries {
+		switch entry.Type {
+		case EntryUser:
 			result := extractUserMessage(entry)
 			if result == nil {
 				continue
 			}
-			flushTools()
+			flushTurn()
 			messages = append(messages, *result)
 
-		case "assistant":
-			result := extractAssistantMessage(entry)
+		case entryFork:
+			flushTurn()
+			fork := Message{Role: "fork", UUID: entry.UUID}
+			for _, branch := range entry.branches {
+				fork.Branches = append(fork.Branches, b.build(branch))
+			}
+			messages = append(messages, fork)
+
+		case EntryAssistant:
+			result := extractAssistantMessage(entry, b.results)
 			if result == nil {
 				continue
 			}
+			for i, call := range result.Tools {
+				if !isAgentTool(call.Name) {
+					continue
+				}
+				if chain := takeSidechain(b.chains, call); chain != nil {
+					result.Tools[i].Subagent = b.build(chain.entries)
+				}
+			}
+			// A response's usage goes on the first message that shows it: its
+			// text, else its tool calls, else its thinking alone.
 			if len(result.Texts) > 0 {
 				flushTools()
-				messages = append(messages, *result)
-			} else if len(result.ToolUses) > 0 {
-				pendingTools = append(pendingTools, result.ToolUses...)
+				text := *result
+				text.Tools = nil
+				text.Thinking = append(pendingThinking, result.Thinking...)
+				text.Usage = b.credit(nil, append(thinkingIDs, entry.Message.ID)...)
+				pendingThinking = nil
+				thinkingIDs = nil
+				messages = append(messages, text)
+			} else if len(result.Thinking) > 0 {
+				flushTools()
+				if len(pendingThinking) == 0 {
+					thinkingTS = result.Timestamp
+					thinkingUUID = result.UUID
+				}
+				pendingThinking = append(pendingThinking, result.Thinking...)
+				thinkingIDs = append(thinkingIDs, entry.Message.ID)
+			}
+			if len(result.Tools) > 0 {
+				// The calls take the usage before the thinking of the same
+				// response is flushed, as the thinking may be hidden.
+				toolsUsage = b.credit(toolsUsage, entry.Message.ID)
+				flushThinking()
+				if len(pendingTools) == 0 {
+					toolsUUID = result.UUID
+				}
 			}
+			pendingTools = append(pendingTools, result.Tools...)
 		}
 	}
 
-	flushTools()
+	flushTurn()
 	return messages
 }
 
-// ExtractMeta extracts session metadata: title, date range, and model name.
-func ExtractMeta(entries []map[string]any) SessionMeta {
+// ExtractMeta extracts session metadata: title, date range, model name and
+// token usage per model. Costs are left for a pricing table to fill in.
+func ExtractMeta(entries []Entry) SessionMeta {
 	var title string
 	var firstTS, lastTS string
 	var model string
+	var cwd string
+	var sessionID string
 
 	for _, entry := range entries {
-		if asString(entry["type"]) == "custom-title" {
-			title = asString(entry["customTitle"])
+		if cwd == "" {
+			cwd = entry.CWD
+		}
+		if sessionID == "" && !entry.IsSidechain {
+			sessionID = entry.SessionID
 		}
 
-		ts := asString(entry["timestamp"])
-		if ts == "" {
-			if snap, ok := entry["snapshot"].(map[string]any); ok {
-				ts = asString(snap["timestamp"])
-			}
+		if entry.Type == EntryCustomTitle {
+			title = entry.CustomTitle
 		}
-		if ts != "" {
+
+		// Subagent entries come after the main ones, so they would move the
+		// end of the session to the end of the last subagent.
+		ts := entry.Timestamp
+		if ts == "" && entry.Snapshot != nil {
+			ts = entry.Snapshot.Timestamp
+		}
+		if ts != "" && !entry.IsSidechain {
 			if firstTS == "" {
 				firstTS = ts
 			}
 			lastTS = ts
 		}
 
-		if model == "" && asString(entry["type"]) == "assistant" {
-			if msg, ok := entry["message"].(map[string]any); ok {
-				model = asString(msg["model"])
-			}
+		if model == "" && entry.Type == EntryAssistant && !entry.IsSidechain && entry.Message != nil {
+			model = entry.Message.Model
 		}
 	}
 
@@ -265,9 +455,15 @@ func ExtractMeta(entries []map[string]any) SessionMeta {
 	}
 
 	return SessionMeta{
+		SessionID: sessionID,
 		Title:     title,
 		DateRange: dateRange,
+		Start:     firstTS,
+		End:       lastTS,
 		Model:     modelDisplay,
+		ModelID:   model,
+		CWD:       cwd,
+		Usage:     modelUsage(entries),
 	}
 }
 
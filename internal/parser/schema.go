@@ -0,0 +1,275 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Entry types written to Claude Code JSONL transcripts.
+const (
+	EntryUser                = "user"
+	EntryAssistant           = "assistant"
+	EntrySummary             = "summary"
+	EntryCustomTitle         = "custom-title"
+	EntryFileHistorySnapshot = "file-history-snapshot"
+	EntrySystem              = "system"
+	EntryAttachment          = "attachment"
+	EntryQueueOperation      = "queue-operation"
+	EntryLastPrompt          = "last-prompt"
+)
+
+// Content block types found in message content lists.
+const (
+	BlockText       = "text"
+	BlockImage      = "image"
+	BlockToolUse    = "tool_use"
+	BlockToolResult = "tool_result"
+	BlockThinking   = "thinking"
+)
+
+// knownEntryTypes lists the entry types the parser understands.
+var knownEntryTypes = map[string]bool{
+	EntryUser:                true,
+	EntryAssistant:           true,
+	EntrySummary:             true,
+	EntryCustomTitle:         true,
+	EntryFileHistorySnapshot: true,
+	EntrySystem:              true,
+	EntryAttachment:          true,
+	EntryQueueOperation:      true,
+	EntryLastPrompt:          true,
+}
+
+// knownBlockTypes lists the content block types the parser understands.
+var knownBlockTypes = map[string]bool{
+	BlockText:       true,
+	BlockImage:      true,
+	BlockToolUse:    true,
+	BlockToolResult: true,
+	BlockThinking:   true,
+}
+
+// Entry is a single line of a session transcript. Fields that only apply to
+// some entry types are left empty for the others.
+type Entry struct {
+	Type       string `json:"type"`
+	UUID       string `json:"uuid,omitempty"`
+	ParentUUID string `json:"parentUuid,omitempty"`
+	// LogicalParentUUID links the first entry after a compaction, whose
+	// parentUuid is null, to the conversation before it.
+	LogicalParentUUID string `json:"logicalParentUuid,omitempty"`
+	SessionID         string `json:"sessionId,omitempty"`
+	Timestamp         string `json:"timestamp,omitempty"`
+	IsSidechain       bool   `json:"isSidechain,omitempty"`
+	IsMeta            bool   `json:"isMeta,omitempty"`
+	AgentID           string `json:"agentId,omitempty"`
+	CWD               string `json:"cwd,omitempty"`
+	GitBranch         string `json:"gitBranch,omitempty"`
+	Version           string `json:"version,omitempty"`
+
+	// user, assistant
+	Message       *MessageBody    `json:"message,omitempty"`
+	RequestID     string          `json:"requestId,omitempty"`
+	ToolUseResult json.RawMessage `json:"toolUseResult,omitempty"`
+
+	// summary, last-prompt
+	Summary    string `json:"summary,omitempty"`
+	LastPrompt string `json:"lastPrompt,omitempty"`
+	LeafUUID   string `json:"leafUuid,omitempty"`
+
+	// custom-title
+	CustomTitle string `json:"customTitle,omitempty"`
+
+	// file-history-snapshot
+	MessageID string    `json:"messageId,omitempty"`
+	Snapshot  *Snapshot `json:"snapshot,omitempty"`
+
+	// system, queue-operation
+	Subtype   string `json:"subtype,omitempty"`
+	Level     string `json:"level,omitempty"`
+	Operation string `json:"operation,omitempty"`
+	Content   string `json:"content,omitempty"`
+
+	// Raw holds the original JSON line for entry types the parser does not know.
+	Raw json.RawMessage `json:"-"`
+
+	// branches holds the abandoned alternatives at a fork marker.
+	branches [][]Entry
+}
+
+// MessageBody is the API message embedded in user and assistant entries.
+type MessageBody struct {
+	ID         string  `json:"id,omitempty"`
+	Role       string  `json:"role"`
+	Model      string  `json:"model,omitempty"`
+	Content    Content `json:"content"`
+	StopReason string  `json:"stop_reason,omitempty"`
+	Usage      *Usage  `json:"usage,omitempty"`
+}
+
+// Usage holds the token counts reported for an assistant API response.
+type Usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// Snapshot is the payload of a file-history-snapshot entry.
+type Snapshot struct {
+	MessageID          string          `json:"messageId,omitempty"`
+	Timestamp          string          `json:"timestamp,omitempty"`
+	TrackedFileBackups json.RawMessage `json:"trackedFileBackups,omitempty"`
+}
+
+// ContentBlock is one element of a message's content list. Fields that only
+// apply to some block types are left empty for the others.
+type ContentBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// image
+	Source *ImageSource `json:"source,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string  `json:"tool_use_id,omitempty"`
+	Content   Content `json:"content,omitempty"`
+	IsError   bool    `json:"is_error,omitempty"`
+
+	// thinking
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+
+	// mistyped names a field of the block that had the wrong JSON type and
+	// was left empty.
+	mistyped string
+}
+
+// ImageSource describes where an image block's data comes from.
+type ImageSource struct {
+	Type      string `json:"type"` // "base64"
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+}
+
+// Content is a list of content blocks. In the transcript it is written either
+// as a plain string or as a list of blocks; a plain string decodes to a single
+// text block.
+type Content []ContentBlock
+
+// UnmarshalJSON accepts both the string and the list form of message content.
+// Blocks are decoded one by one, so that a field of the wrong type only
+// empties that field; the block records it for Transcript.Mistyped.
+func (c *Content) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*c = Content{{Type: BlockText, Text: s}}
+		return nil
+	}
+	if string(data) == "null" {
+		*c = nil
+		return nil
+	}
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		if !isTypeError(err) {
+			return err
+		}
+		*c = Content{{mistyped: "content"}}
+		return nil
+	}
+	blocks := make(Content, len(raws))
+	for i, raw := range raws {
+		if err := json.Unmarshal(raw, &blocks[i]); err != nil {
+			var te *json.UnmarshalTypeError
+			if !errors.As(err, &te) {
+				return err
+			}
+			blocks[i].mistyped = "content." + te.Field
+			if te.Field == "" {
+				blocks[i].mistyped = "content"
+			}
+		}
+	}
+	*c = blocks
+	return nil
+}
+
+// isTypeError reports whether err is a JSON value of the wrong type for its
+// Go field, as opposed to invalid JSON.
+func isTypeError(err error) bool {
+	var te *json.UnmarshalTypeError
+	return errors.As(err, &te)
+}
+
+// MistypedField records a field whose JSON value had the wrong type. The
+// field was left empty and the rest of its entry kept.
+type MistypedField struct {
+	Field string // dotted path, e.g. "message.usage.input_tokens"
+	File  string // transcript file the entry was found in
+	Line  int    // 1-based line number in File
+}
+
+// UnknownType records an entry or content block type the parser did not recognise.
+type UnknownType struct {
+	Kind string // "entry" or "block"
+	Type string
+	File string // transcript file the type was found in
+	Line int    // 1-based line number in File
+}
+
+func (u UnknownType) String() string {
+	return fmt.Sprintf("unknown %s type %q (line %d)", u.Kind, u.Type, u.Line)
+}
+
+// Transcript is the decoded contents of a session JSONL file.
+type Transcript struct {
+	Entries   []Entry
+	Unknown   []UnknownType   // unrecognised entry and block types, in file order
+	Mistyped  []MistypedField // fields of the wrong type, in file order
+	Malformed int             // lines that were not valid JSON
+}
+
+// mistypedBlocks returns the mistyped fields of the blocks in content,
+// including those nested inside tool results.
+func mistypedBlocks(content Content) []string {
+	var fields []string
+	for _, b := range content {
+		if b.mistyped != "" {
+			fields = append(fields, b.mistyped)
+		}
+		if b.Type == BlockToolResult {
+			fields = append(fields, mistypedBlocks(b.Content)...)
+		}
+	}
+	return fields
+}
+
+// unknownBlocks returns the unrecognised block types in content, including
+// those nested inside tool results.
+func unknownBlocks(content Content) []string {
+	var types []string
+	for _, b := range content {
+		if b.mistyped != "" && b.Type == "" {
+			continue // reported as mistyped
+		}
+		if !knownBlockTypes[b.Type] {
+			types = append(types, b.Type)
+		}
+		if b.Type == BlockToolResult {
+			types = append(types, unknownBlocks(b.Content)...)
+		}
+	}
+	return types
+}
@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ParseSession parses a session transcript together with the subagent
+// transcripts that belong to it. Subagent entries are appended to the
+// session's entries as sidechain entries; they are found in
+// <project>/<session-id>/subagents/*.jsonl and, for older versions of
+// Claude Code, in <project>/agent-*.jsonl files that name the session.
+// Like ParseFile, if the session's transcript could only be read in part,
+// the entries read are returned along with the error. Subagent transcripts
+// that could not be read, in whole or in part, are reported the same way,
+// joined with that error.
+func ParseSession(path string) (*Transcript, error) {
+	t, err := ParseFile(path)
+	if t == nil {
+		return nil, err
+	}
+
+	errs := []error{err}
+	sessionID := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+	for _, file := range subagentFiles(path, sessionID) {
+		sub, err := ParseFile(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("subagent %s: %w", filepath.Base(file), err))
+		}
+		if sub == nil || len(sub.Entries) == 0 {
+			continue
+		}
+		agentID := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "agent-"), ".jsonl")
+		for _, e := range sub.Entries {
+			e.IsSidechain = true
+			if e.AgentID == "" {
+				e.AgentID = agentID
+			}
+			t.Entries = append(t.Entries, e)
+		}
+		t.Unknown = append(t.Unknown, sub.Unknown...)
+		t.Mistyped = append(t.Mistyped, sub.Mistyped...)
+		t.Malformed += sub.Malformed
+	}
+	return t, errors.Join(errs...)
+}
+
+// IsAgentFile reports whether a transcript file holds a subagent
+// conversation rather than a top-level session.
+func IsAgentFile(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), "agent-")
+}
+
+// SessionFiles lists the files ParseSession reads for a session: the
+// transcript at path followed by its subagent transcripts.
+func SessionFiles(path string) []string {
+	sessionID := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+	return append([]string{path}, subagentFiles(path, sessionID)...)
+}
+
+// subagentFiles lists the subagent transcripts belonging to a session.
+func subagentFiles(path, sessionID string) []string {
+	dir := filepath.Dir(path)
+	files, _ := filepath.Glob(filepath.Join(dir, sessionID, "subagents", "*.jsonl"))
+
+	legacy, _ := filepath.Glob(filepath.Join(dir, "agent-*.jsonl"))
+	for _, file := range legacy {
+		if firstSessionID(file) == sessionID {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// firstSessionID returns the sessionId recorded on the first entry of a
+// transcript that has one.
+func firstSessionID(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var head struct {
+			SessionID string `json:"sessionId"`
+		}
+		if err := dec.Decode(&head); err != nil {
+			return ""
+		}
+		if head.SessionID != "" {
+			return head.SessionID
+		}
+	}
+	return ""
+}
+
+// isAgentTool reports whether a tool launches a subagent.
+func isAgentTool(name string) bool {
+	return name == "Task" || name == "Agent"
+}
+
+// sidechain is one subagent conversation found in a transcript.
+type sidechain struct {
+	agentID string
+	prompt  string // text of the first user message, as sent by the Task call
+	entries []Entry
+	used    bool
+}
+
+// splitSidechains separates main-thread entries from subagent conversations.
+// Sidechain entries are grouped by agentId when present, otherwise by the
+// root of their parentUuid chain.
+func splitSidechains(entries []Entry) (mainEntries []Entry, chains []*sidechain) {
+	byAgent := make(map[string]*sidechain)
+	byUUID := make(map[string]*sidechain)
+
+	for _, e := range entries {
+		if !e.IsSidechain {
+			mainEntries = append(mainEntries, e)
+			continue
+		}
+
+		var chain *sidechain
+		switch {
+		case e.AgentID != "":
+			chain = byAgent[e.AgentID]
+		case e.ParentUUID != "":
+			chain = byUUID[e.ParentUUID]
+		}
+		if chain == nil {
+			chain = &sidechain{agentID: e.AgentID}
+			chains = append(chains, chain)
+			if e.AgentID != "" {
+				byAgent[e.AgentID] = chain
+			}
+		}
+		if e.UUID != "" {
+			byUUID[e.UUID] = chain
+		}
+		if chain.prompt == "" && e.Type == EntryUser && e.Message != nil {
+			chain.prompt = strings.TrimSpace(resultText(e.Message.Content))
+		}
+		chain.entries = append(chain.entries, e)
+	}
+	return mainEntries, chains
+}
+
+// takeSidechain returns the unused subagent conversation launched by call,
+// matched by agent ID or, failing that, by the prompt it was given.
+func takeSidechain(chains []*sidechain, call ToolCall) *sidechain {
+	if call.AgentID != "" {
+		for _, c := range chains {
+			if !c.used && c.agentID == call.AgentID {
+				c.used = true
+				return c
+			}
+		}
+	}
+	prompt := strings.TrimSpace(call.Params().Prompt)
+	if prompt == "" {
+		return nil
+	}
+	for _, c := range chains {
+		if !c.used && c.prompt == prompt {
+			c.used = true
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+package parser
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/HabibPro1999/shiplog/internal/diff"
+)
+
+// ToolCall is a tool_use block paired with its matching tool_result.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Input     json.RawMessage // replace with SetInput, which resets the cached diff
+	Result    string          // text content of the tool_result
+	IsError   bool
+	HasResult bool // false if no tool_result was found for this call
+
+	// Task calls: the subagent's ID and its own conversation, if found.
+	AgentID  string
+	Subagent []Message
+
+	hunks *hunkCache // Diff's result, shared by copies of the call
+}
+
+// hunkCache holds the diff of an edit call, computed on first use.
+type hunkCache struct {
+	once  sync.Once
+	hunks [][]diff.Line
+}
+
+// newToolCall returns a call whose diff is computed at most once.
+func newToolCall(id, name string, input json.RawMessage) ToolCall {
+	return ToolCall{ID: id, Name: name, Input: input, hunks: new(hunkCache)}
+}
+
+// SetInput replaces the call's input, such as with a redacted copy, and
+// discards the diff computed from the old one.
+func (c *ToolCall) SetInput(input json.RawMessage) {
+	c.Input = input
+	c.hunks = new(hunkCache)
+}
+
+// ToolInput holds the input fields used by the built-in tools. Fields that a
+// tool does not take are left empty.
+type ToolInput struct {
+	Command      string `json:"command"`
+	Description  string `json:"description"`
+	FilePath     string `json:"file_path"`
+	Path         string `json:"path"`
+	Pattern      string `json:"pattern"`
+	Glob         string `json:"glob"`
+	OutputMode   string `json:"output_mode"`
+	Offset       int    `json:"offset"`
+	Limit        int    `json:"limit"`
+	URL          string `json:"url"`
+	Query        string `json:"query"`
+	Prompt       string `json:"prompt"`
+	SubagentType string `json:"subagent_type"`
+	Skill        string `json:"skill"`
+	OldString    string `json:"old_string"`
+	NewString    string `json:"new_string"`
+	ReplaceAll   bool   `json:"replace_all"`
+	Content      string `json:"content"`
+	Edits        []Edit `json:"edits"`
+}
+
+// Edit is a single string replacement made by the Edit or MultiEdit tools.
+// A Write is represented as an Edit with an empty OldString.
+type Edit struct {
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all"`
+}
+
+// FileChange summarises the edits made to one file over a session.
+type FileChange struct {
+	Path    string
+	Added   int
+	Removed int
+	Edits   int // number of Edit, MultiEdit and Write calls touching the file
+}
+
+// Params decodes the call's input into a ToolInput. Unknown or malformed
+// inputs yield an empty ToolInput.
+func (c ToolCall) Params() ToolInput {
+	var in ToolInput
+	if len(c.Input) > 0 {
+		_ = json.Unmarshal(c.Input, &in)
+	}
+	return in
+}
+
+// Summary returns a one-line description of what the call operated on:
+// the command for Bash, the pattern for Grep and Glob, the path for file tools.
+func (c ToolCall) Summary() string {
+	in := c.Params()
+	switch c.Name {
+	case "Bash":
+		return firstLine(in.Command)
+	case "Grep":
+		if in.Path != "" {
+			return in.Pattern + " in " + in.Path
+		}
+		return in.Pattern
+	case "Glob":
+		return in.Pattern
+	case "Read", "Write", "Edit", "MultiEdit", "NotebookEdit":
+		return in.FilePath
+	case "WebFetch":
+		return in.URL
+	case "WebSearch":
+		return in.Query
+	case "Task", "Agent":
+		return in.Description
+	case "Skill":
+		return in.Skill
+	}
+	return ""
+}
+
+// Detail returns a short annotation derived from the input and result,
+// such as the match count of a Grep, the line range of a Read or the lines
+// changed by a successful edit.
+func (c ToolCall) Detail() string {
+	switch c.Name {
+	case "Grep":
+		if n, ok := c.HitCount(); ok {
+			if c.Params().OutputMode == "content" {
+				return Plural(n, "match", "matches")
+			}
+			return Plural(n, "file", "files")
+		}
+	case "Glob":
+		if n, ok := c.HitCount(); ok {
+			return Plural(n, "file", "files")
+		}
+	case "Edit", "MultiEdit", "Write":
+		// A failed edit changed nothing.
+		if c.IsError {
+			return ""
+		}
+		added, removed := 0, 0
+		for _, hunk := range c.Diff() {
+			a, r := diff.Stats(hunk)
+			added += a
+			removed += r
+		}
+		return "+" + strconv.Itoa(added) + " −" + strconv.Itoa(removed)
+	case "Read":
+		if start, end := c.LineRange(); end > 0 {
+			return "lines " + strconv.Itoa(start) + "–" + strconv.Itoa(end)
+		}
+	}
+	return ""
+}
+
+// FileEdits returns the path and replacements made by an Edit, MultiEdit or
+// Write call. ok is false for other tools.
+func (c ToolCall) FileEdits() (path string, edits []Edit, ok bool) {
+	in := c.Params()
+	switch c.Name {
+	case "Edit":
+		return in.FilePath, []Edit{{OldString: in.OldString, NewString: in.NewString, ReplaceAll: in.ReplaceAll}}, true
+	case "MultiEdit":
+		return in.FilePath, in.Edits, true
+	case "Write":
+		return in.FilePath, []Edit{{NewString: in.Content}}, true
+	}
+	return "", nil, false
+}
+
+// Diff returns the line diff of each replacement made by an Edit, MultiEdit
+// or Write call, in order. It returns nil for other tools. The diff is
+// computed once per parsed call; callers must not modify it.
+func (c ToolCall) Diff() [][]diff.Line {
+	if c.hunks == nil {
+		return c.diff()
+	}
+	c.hunks.once.Do(func() { c.hunks.hunks = c.diff() })
+	return c.hunks.hunks
+}
+
+// diff computes the hunks returned by Diff.
+func (c ToolCall) diff() [][]diff.Line {
+	_, edits, ok := c.FileEdits()
+	if !ok {
+		return nil
+	}
+	hunks := make([][]diff.Line, 0, len(edits))
+	for _, e := range edits {
+		hunks = append(hunks, diff.Lines(e.OldString, e.NewString))
+	}
+	return hunks
+}
+
+// FileChanges totals the lines added and removed by successful Edit,
+// MultiEdit and Write calls, per file, in the order files were first touched.
+// Calls made by subagents are included.
+func FileChanges(messages []Message) []FileChange {
+	var changes []FileChange
+	index := make(map[string]int)
+	var walk func(messages []Message)
+	walk = func(messages []Message) {
+		for _, msg := range messages {
+			for _, call := range msg.Tools {
+				walk(call.Subagent)
+				path, _, ok := call.FileEdits()
+				if !ok || path == "" || call.IsError {
+					continue
+				}
+				i, seen := index[path]
+				if !seen {
+					i = len(changes)
+					index[path] = i
+					changes = append(changes, FileChange{Path: path})
+				}
+				for _, hunk := range call.Diff() {
+					added, removed := diff.Stats(hunk)
+					changes[i].Added += added
+					changes[i].Removed += removed
+				}
+				changes[i].Edits++
+			}
+		}
+	}
+	walk(messages)
+	return changes
+}
+
+// foundCountRe matches the "Found N files" header of Grep and Glob results.
+var foundCountRe = regexp.MustCompile(`^Found (\d+) `)
+
+// HitCount returns the number of matches reported by a search tool result.
+func (c ToolCall) HitCount() (int, bool) {
+	if !c.HasResult || c.IsError {
+		return 0, false
+	}
+	out := strings.TrimSpace(c.Result)
+	if out == "" || strings.HasPrefix(out, "No files found") || strings.HasPrefix(out, "No matches found") {
+		return 0, true
+	}
+	if m := foundCountRe.FindStringSubmatch(out); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n, true
+	}
+	n := 0
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) != "" {
+			n++
+		}
+	}
+	return n, true
+}
+
+// LineRange returns the first and last line a Read call returned, or
+// (0, 0) if it cannot be determined.
+func (c ToolCall) LineRange() (start, end int) {
+	if !c.HasResult || c.IsError {
+		return 0, 0
+	}
+	in := c.Params()
+	start = in.Offset
+	if start < 1 {
+		start = 1
+	}
+	if strings.TrimSpace(c.Result) == "" {
+		return 0, 0
+	}
+	lines := strings.Count(strings.TrimRight(c.Result, "\n"), "\n") + 1
+	if in.Limit > 0 && lines > in.Limit {
+		lines = in.Limit
+	}
+	return start, start + lines - 1
+}
+
+// resultText joins the text blocks of a tool_result's content.
+func resultText(content Content) string {
+	var parts []string
+	for _, b := range content {
+		switch b.Type {
+		case BlockText:
+			parts = append(parts, b.Text)
+		case BlockImage:
+			parts = append(parts, "[image]")
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// toolResult is a tool_result block and the metadata Claude Code recorded
+// alongside it.
+type toolResult struct {
+	block   ContentBlock
+	agentID string // Task results: the subagent that produced it
+}
+
+// collectToolResults indexes every tool_result block in the transcript by
+// the tool_use_id it answers.
+func collectToolResults(entries []Entry) map[string]toolResult {
+	results := make(map[string]toolResult)
+	for _, entry := range entries {
+		if entry.Type != EntryUser || entry.Message == nil {
+			continue
+		}
+		var meta struct {
+			AgentID string `json:"agentId"`
+		}
+		if len(entry.ToolUseResult) > 0 {
+			_ = json.Unmarshal(entry.ToolUseResult, &meta)
+		}
+		for _, block := range entry.Message.Content {
+			if block.Type == BlockToolResult && block.ToolUseID != "" {
+				results[block.ToolUseID] = toolResult{block: block, agentID: meta.AgentID}
+			}
+		}
+	}
+	return results
+}
+
+// firstLine returns the first non-empty line of s.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if t := strings.TrimSpace(line); t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+// Plural formats n followed by the singular or plural noun.
+func Plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return strconv.Itoa(n) + " " + many
+}
@@ -0,0 +1,198 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Branch selection modes for BuildConversation. Any other value is taken as
+// the UUID (or unique UUID prefix) of an entry on the branch to show.
+const (
+	BranchLatest = "latest" // only the path to the most recent leaf
+	BranchAll    = "all"    // the latest path, with abandoned branches as forks
+)
+
+// entryFork marks a point in a selected path where abandoned branches split
+// off. It only exists between tree selection and building messages.
+const entryFork = "shiplog-fork"
+
+// tree is the message tree of a session's main thread, linked by
+// uuid/parentUuid. Compaction restarts the chain with a null parentUuid;
+// those entries are linked through logicalParentUuid instead, or failing
+// that to the previous entry in the file, so that only the first entry is
+// a root.
+type tree struct {
+	entries  []Entry
+	byUUID   map[string]int
+	parent   []int   // index of each entry's parent, -1 for roots and entries without a UUID
+	children [][]int // child indexes in file order
+	latest   []int   // most recent entry (by file order) in each subtree
+}
+
+// newTree links entries by UUID. Entries without a UUID are not part of the tree.
+func newTree(entries []Entry) *tree {
+	t := &tree{
+		entries:  entries,
+		byUUID:   make(map[string]int),
+		parent:   make([]int, len(entries)),
+		children: make([][]int, len(entries)),
+		latest:   make([]int, len(entries)),
+	}
+	for i, e := range entries {
+		if e.UUID != "" {
+			t.byUUID[e.UUID] = i
+		}
+	}
+	prev := -1
+	for i, e := range entries {
+		t.parent[i] = -1
+		t.latest[i] = i
+		if e.UUID == "" {
+			continue
+		}
+		p, ok := t.byUUID[e.ParentUUID]
+		if !ok || p >= i {
+			p, ok = t.byUUID[e.LogicalParentUUID]
+		}
+		// Only link to earlier entries, which also rules out cycles.
+		if !ok || p >= i {
+			p = prev
+		}
+		if p >= 0 {
+			t.parent[i] = p
+			t.children[p] = append(t.children[p], i)
+		}
+		prev = i
+	}
+	// Children always follow their parent, so a reverse sweep sees every
+	// subtree before its root.
+	for i := len(entries) - 1; i >= 0; i-- {
+		if p := t.parent[i]; p >= 0 && t.latest[i] > t.latest[p] {
+			t.latest[p] = t.latest[i]
+		}
+	}
+	return t
+}
+
+// lastLeaf returns the most recent entry with a UUID, or -1 if there is none.
+func (t *tree) lastLeaf() int {
+	for i := len(t.entries) - 1; i >= 0; i-- {
+		if t.entries[i].UUID != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// find resolves a full or prefix UUID to an entry index.
+func (t *tree) find(id string) (int, error) {
+	if i, ok := t.byUUID[id]; ok {
+		return i, nil
+	}
+	match := -1
+	for uuid, i := range t.byUUID {
+		if strings.HasPrefix(uuid, id) {
+			if match >= 0 {
+				return -1, fmt.Errorf("branch %q matches more than one entry", id)
+			}
+			match = i
+		}
+	}
+	if match < 0 {
+		return -1, fmt.Errorf("no entry with uuid %q", id)
+	}
+	return match, nil
+}
+
+// path returns the entries from top down to leaf, where top is leaf or one
+// of its ancestors; a top of -1 starts at the root. Tool results that were
+// recorded as siblings of the path, rather than on it, are kept. With forks
+// set, abandoned branches are inserted as fork markers after the entry they
+// split from.
+func (t *tree) path(top, leaf int, forks bool) []Entry {
+	var chain []int
+	for i := leaf; i >= 0; i = t.parent[i] {
+		chain = append(chain, i)
+		if i == top {
+			break
+		}
+	}
+
+	var out []Entry
+	for k := len(chain) - 1; k >= 0; k-- {
+		i := chain[k]
+		out = append(out, t.entries[i])
+
+		next := -1
+		if k > 0 {
+			next = chain[k-1]
+		}
+		var branches [][]Entry
+		for _, c := range t.children[i] {
+			switch {
+			case c == next:
+			case !t.hasMessages(c):
+				out = append(out, t.subtree(c)...)
+			case forks:
+				branches = append(branches, t.path(c, t.latest[c], true))
+			}
+		}
+		if len(branches) > 0 {
+			out = append(out, Entry{Type: entryFork, UUID: branches[0][0].UUID, branches: branches})
+		}
+	}
+	return out
+}
+
+// subtree returns entry i and all its descendants in file order.
+func (t *tree) subtree(i int) []Entry {
+	out := []Entry{t.entries[i]}
+	for _, c := range t.children[i] {
+		out = append(out, t.subtree(c)...)
+	}
+	return out
+}
+
+// hasMessages reports whether the subtree rooted at i contains a user prompt
+// or an assistant message, i.e. whether it is a real alternative branch rather
+// than a stray tool result or attachment.
+func (t *tree) hasMessages(i int) bool {
+	e := t.entries[i]
+	if e.Type == EntryAssistant || (e.Type == EntryUser && extractUserMessage(e) != nil) {
+		return true
+	}
+	for _, c := range t.children[i] {
+		if t.hasMessages(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildConversation builds the messages of one branch of the conversation
+// tree. branch is BranchLatest, BranchAll or the UUID (or UUID prefix) of an
+// entry; for a UUID, the branch runs through that entry to the most recent
+// leaf below it. Transcripts without UUIDs are built in file order.
+func BuildConversation(entries []Entry, branch string) ([]Message, error) {
+	mainEntries, chains := splitSidechains(entries)
+	b := newBuilder(entries, chains)
+
+	t := newTree(mainEntries)
+	leaf := t.lastLeaf()
+	if leaf < 0 {
+		return b.build(mainEntries), nil
+	}
+
+	switch branch {
+	case BranchLatest, BranchAll, "":
+	default:
+		i, err := t.find(branch)
+		if err != nil {
+			return nil, err
+		}
+		leaf = t.latest[i]
+	}
+
+	path := t.path(-1, leaf, branch == BranchAll)
+	return b.build(path), nil
+}
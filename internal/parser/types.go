@@ -2,12 +2,15 @@ package parser
 
 // Message represents a single chat message in the parsed output.
 type Message struct {
-	Role      string // "user", "assistant", "tool_group"
+	Role      string // "user", "assistant", "tool_group", "fork"
 	Texts     []string
+	Thinking  []string // assistant: reasoning from thinking blocks
 	Images    []Image
-	ToolUses  []string // assistant: tool names used in this message
-	ToolNames []string // tool_group: accumulated tool names
+	Tools     []ToolCall // tool_group: accumulated tool calls with their results
 	Timestamp string
+	Usage     *Usage      // assistant, tool_group: tokens of the API responses first shown by this message
+	UUID      string      // uuid of the first entry that produced this message; fork: of the first abandoned entry
+	Branches  [][]Message // fork: abandoned alternatives to the messages that follow
 }
 
 // Image holds a base64-encoded image from a user message.
@@ -18,7 +21,35 @@ type Image struct {
 
 // SessionMeta holds extracted metadata about a session.
 type SessionMeta struct {
+	SessionID string
 	Title     string
 	DateRange string
-	Model     string
+	Start     string       // timestamp of the first entry, as recorded
+	End       string       // timestamp of the last entry, as recorded
+	Model     string       // display name: "Claude Sonnet"
+	ModelID   string       // main-thread model id as recorded, empty if none
+	CWD       string       // working directory recorded on the first entry that has one
+	Usage     []ModelUsage // token totals per model, including subagents
+}
+
+// TotalTokens sums token usage across all models.
+func (m SessionMeta) TotalTokens() TokenUsage {
+	var total TokenUsage
+	for _, u := range m.Usage {
+		total.Merge(u.Tokens)
+	}
+	return total
+}
+
+// TotalCost sums the estimated cost across all models. priced is false if
+// any model with usage had no known price.
+func (m SessionMeta) TotalCost() (cost float64, priced bool) {
+	priced = true
+	for _, u := range m.Usage {
+		cost += u.Cost
+		if !u.Priced && u.Tokens.Total() > 0 {
+			priced = false
+		}
+	}
+	return cost, priced
 }
@@ -0,0 +1,86 @@
+package parser
+
+// TokenUsage totals the token counts of one or more API responses.
+type TokenUsage struct {
+	Input         int
+	Output        int
+	CacheCreation int
+	CacheRead     int
+}
+
+// Add accumulates the counts of a single response.
+func (t *TokenUsage) Add(u Usage) {
+	t.Input += u.InputTokens
+	t.Output += u.OutputTokens
+	t.CacheCreation += u.CacheCreationInputTokens
+	t.CacheRead += u.CacheReadInputTokens
+}
+
+// Merge accumulates another total.
+func (t *TokenUsage) Merge(o TokenUsage) {
+	t.Input += o.Input
+	t.Output += o.Output
+	t.CacheCreation += o.CacheCreation
+	t.CacheRead += o.CacheRead
+}
+
+// Total returns the sum of all token counts.
+func (t TokenUsage) Total() int {
+	return t.Input + t.Output + t.CacheCreation + t.CacheRead
+}
+
+// ModelUsage holds the token totals and estimated cost of one model.
+type ModelUsage struct {
+	Model  string // model id as recorded, e.g. "claude-sonnet-4-5-20250929"
+	Tokens TokenUsage
+	Cost   float64 // estimated USD, filled in by a pricing table
+	Priced bool    // false if no price was known for Model
+}
+
+// responseUsage returns the final usage of each API response, keyed by
+// message id. Claude Code writes one entry per content block, each repeating
+// the usage of the response; the last one recorded wins.
+func responseUsage(entries []Entry) map[string]Usage {
+	usage := make(map[string]Usage)
+	for _, e := range entries {
+		if e.Type == EntryAssistant && e.Message != nil && e.Message.ID != "" && e.Message.Usage != nil {
+			usage[e.Message.ID] = *e.Message.Usage
+		}
+	}
+	return usage
+}
+
+// modelUsage totals token usage per model over all assistant responses,
+// including those of subagents, in order of first use.
+func modelUsage(entries []Entry) []ModelUsage {
+	final := responseUsage(entries)
+	seen := make(map[string]bool)
+	index := make(map[string]int)
+	var models []ModelUsage
+
+	for _, e := range entries {
+		if e.Type != EntryAssistant || e.Message == nil || e.Message.Usage == nil {
+			continue
+		}
+		u := *e.Message.Usage
+		if id := e.Message.ID; id != "" {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			u = final[id]
+		}
+		model := e.Message.Model
+		if model == "" || model == "<synthetic>" {
+			continue
+		}
+		i, ok := index[model]
+		if !ok {
+			i = len(models)
+			index[model] = i
+			models = append(models, ModelUsage{Model: model})
+		}
+		models[i].Tokens.Add(u)
+	}
+	return models
+}
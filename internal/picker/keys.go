@@ -0,0 +1,94 @@
+package picker
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Key codes returned by parseKey.
+const (
+	keyNone = iota
+	keyRune
+	keyEnter
+	keyCancel
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyBackspace
+	keyClear
+)
+
+// key is one key press read from the terminal.
+type key struct {
+	code int
+	r    rune // keyRune: the character typed
+}
+
+// sequences maps the escape sequences of special keys to key codes.
+// Terminals send either the CSI ("\x1b[") or the SS3 ("\x1bO") form.
+var sequences = map[string]int{
+	"\x1b[A":  keyUp,
+	"\x1bOA":  keyUp,
+	"\x1b[B":  keyDown,
+	"\x1bOB":  keyDown,
+	"\x1b[5~": keyPageUp,
+	"\x1b[6~": keyPageDown,
+	"\x1b[H":  keyHome,
+	"\x1bOH":  keyHome,
+	"\x1b[1~": keyHome,
+	"\x1b[7~": keyHome,
+	"\x1b[F":  keyEnd,
+	"\x1bOF":  keyEnd,
+	"\x1b[4~": keyEnd,
+	"\x1b[8~": keyEnd,
+}
+
+// parseKey decodes the first key press in b and returns it with the number
+// of bytes it used. Unknown escape sequences and control characters are
+// skipped as keyNone.
+func parseKey(b []byte) (key, int) {
+	switch c := b[0]; c {
+	case '\r', '\n':
+		return key{code: keyEnter}, 1
+	case 3, 4: // Ctrl-C, Ctrl-D
+		return key{code: keyCancel}, 1
+	case 127, 8: // Backspace, Ctrl-H
+		return key{code: keyBackspace}, 1
+	case 21: // Ctrl-U
+		return key{code: keyClear}, 1
+	case 16: // Ctrl-P
+		return key{code: keyUp}, 1
+	case 14: // Ctrl-N
+		return key{code: keyDown}, 1
+	case 0x1b:
+		if len(b) == 1 {
+			return key{code: keyCancel}, 1
+		}
+		for seq, code := range sequences {
+			if bytes.HasPrefix(b, []byte(seq)) {
+				return key{code: code}, len(seq)
+			}
+		}
+		if b[1] != '[' && b[1] != 'O' {
+			// Esc followed by another key: Alt+key, or Esc typed quickly.
+			return key{code: keyCancel}, 1
+		}
+		// Skip an unknown sequence up to its final byte.
+		for i := 2; i < len(b); i++ {
+			if b[i] >= 0x40 && b[i] <= 0x7e {
+				return key{code: keyNone}, i + 1
+			}
+		}
+		return key{code: keyNone}, len(b)
+	}
+
+	r, size := utf8.DecodeRune(b)
+	if r == utf8.RuneError || !unicode.IsPrint(r) {
+		return key{code: keyNone}, size
+	}
+	return key{code: keyRune, r: r}, size
+}
@@ -0,0 +1,352 @@
+// Package picker lets the user choose a session in a full-screen terminal
+// list that filters as they type.
+package picker
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/HabibPro1999/shiplog/internal/parser"
+	"github.com/HabibPro1999/shiplog/internal/session"
+	"golang.org/x/term"
+)
+
+// Available reports whether both stdin and stdout are terminals, so that
+// the picker can be shown.
+func Available() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// Run shows sessions, filtered by filter to begin with, and returns the one
+// the user picks with Enter, or nil if they cancel with Esc or Ctrl-C.
+// Typing edits the filter, which ranks sessions like session.FindByQuery;
+// the arrow keys move the selection, and a preview shows the selected
+// session's first prompts and stats.
+func Run(sessions []session.SessionInfo, filter string) (*session.SessionInfo, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	// Draw on the alternate screen, so that the shell's scrollback is left
+	// as it was.
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	// A signal may arrive while Run returns, so restore runs only once.
+	var once sync.Once
+	restore := func() {
+		once.Do(func() {
+			fmt.Print("\x1b[?25h\x1b[?1049l")
+			term.Restore(fd, state)
+		})
+	}
+	defer restore()
+
+	// Leave the terminal usable if shiplog is killed or its terminal closed
+	// while the picker is open.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGHUP)
+	done := make(chan struct{})
+	defer func() {
+		signal.Stop(sigs)
+		close(done)
+	}()
+	go func() {
+		select {
+		case sig := <-sigs:
+			restore()
+			code := 1
+			if s, ok := sig.(syscall.Signal); ok {
+				code = 128 + int(s)
+			}
+			os.Exit(code)
+		case <-done:
+		}
+	}()
+
+	p := &picker{all: sessions, filter: []rune(filter), prompts: make(map[string][]string), now: time.Now()}
+	p.refilter()
+
+	buf := make([]byte, 256)
+	for {
+		p.draw()
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		for in := buf[:n]; len(in) > 0; {
+			k, size := parseKey(in)
+			in = in[size:]
+			switch k.code {
+			case keyEnter:
+				if len(p.items) == 0 {
+					continue
+				}
+				s := p.items[p.cursor]
+				return &s, nil
+			case keyCancel:
+				return nil, nil
+			default:
+				p.handle(k)
+			}
+		}
+	}
+}
+
+// picker is the state of the list.
+type picker struct {
+	all     []session.SessionInfo
+	items   []session.SessionInfo // all, filtered and ranked
+	filter  []rune
+	cursor  int                 // index in items of the selection
+	top     int                 // index in items of the first visible row
+	prompts map[string][]string // first prompts by transcript path
+	now     time.Time
+	rows    int // list rows of the last drawn frame
+}
+
+// refilter ranks the sessions against the filter and selects the first.
+// Sessions whose UUID starts with the filter come first.
+func (p *picker) refilter() {
+	p.cursor, p.top = 0, 0
+	f := strings.TrimSpace(string(p.filter))
+	if f == "" {
+		p.items = p.all
+		return
+	}
+	p.items = nil
+	byID := make(map[string]bool)
+	for _, s := range p.all {
+		if strings.HasPrefix(strings.ToLower(s.SessionID), strings.ToLower(f)) {
+			p.items = append(p.items, s)
+			byID[s.SessionID] = true
+		}
+	}
+	for _, c := range session.Rank(p.all, f, p.now) {
+		if !byID[c.Session.SessionID] {
+			p.items = append(p.items, c.Session)
+		}
+	}
+}
+
+// handle applies a key other than Enter and cancel.
+func (p *picker) handle(k key) {
+	page := max(p.rows-1, 1)
+	switch k.code {
+	case keyUp:
+		p.move(-1)
+	case keyDown:
+		p.move(1)
+	case keyPageUp:
+		p.move(-page)
+	case keyPageDown:
+		p.move(page)
+	case keyHome:
+		p.move(-len(p.items))
+	case keyEnd:
+		p.move(len(p.items))
+	case keyBackspace:
+		if len(p.filter) > 0 {
+			p.filter = p.filter[:len(p.filter)-1]
+			p.refilter()
+		}
+	case keyClear:
+		if len(p.filter) > 0 {
+			p.filter = nil
+			p.refilter()
+		}
+	case keyRune:
+		p.filter = append(p.filter, k.r)
+		p.refilter()
+	}
+}
+
+// move moves the selection by delta rows, within the list.
+func (p *picker) move(delta int) {
+	p.cursor = max(0, min(p.cursor+delta, len(p.items)-1))
+}
+
+// draw renders a frame: the filter, the list and the preview.
+func (p *picker) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 20 || height < 6 {
+		width, height = 80, 24
+	}
+
+	previewRows := 0
+	if height >= 16 {
+		previewRows = min(10, height/3)
+	}
+	p.rows = height - 2 - previewRows
+	if previewRows > 0 {
+		p.rows-- // the rule above the preview
+	}
+	if p.cursor < p.top {
+		p.top = p.cursor
+	}
+	if p.cursor >= p.top+p.rows {
+		p.top = p.cursor - p.rows + 1
+	}
+
+	var lines []string
+	lines = append(lines, fit("  > "+string(p.filter)+"█", width))
+	lines = append(lines, dim(fit(fmt.Sprintf("  %d of %d sessions · ↑↓ move · Enter export · Esc cancel", len(p.items), len(p.all)), width)))
+	for i := p.top; i < p.top+p.rows; i++ {
+		if i >= len(p.items) {
+			lines = append(lines, "")
+			continue
+		}
+		row := fit(listRow(p.items[i], width), width)
+		if i == p.cursor {
+			row = "\x1b[7m" + row + strings.Repeat(" ", max(width-utf8.RuneCountInString(row), 0)) + "\x1b[0m"
+		}
+		lines = append(lines, row)
+	}
+	if previewRows > 0 {
+		lines = append(lines, dim(strings.Repeat("─", width)))
+		var preview []styled
+		if len(p.items) > 0 {
+			preview = p.preview(p.items[p.cursor])
+		}
+		for i := range previewRows {
+			line := ""
+			if i < len(preview) {
+				line = fit(preview[i].text, width)
+				if preview[i].style != "" {
+					line = preview[i].style + line + "\x1b[0m"
+				}
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	// Raw mode does not translate \n, so lines end with \r\n.
+	fmt.Print("\x1b[H" + strings.Join(lines, "\x1b[K\r\n") + "\x1b[K\x1b[J")
+}
+
+// listRow formats a session as one row of the list.
+func listRow(s session.SessionInfo, width int) string {
+	shortID := s.SessionID
+	if len(shortID) > 8 {
+		shortID = shortID[:8]
+	}
+	titleWidth := max(min(40, width-36), 10)
+	return fmt.Sprintf("  %-*s  %-8s  %-12s  %s",
+		titleWidth, fit(s.Title, titleWidth), shortID, formatTime(s.Timestamp, "Jan 02, 2006"), s.Project)
+}
+
+// maxPrompts is the number of prompts shown in the preview.
+const maxPrompts = 5
+
+// styled is a line of text and the escape sequence that styles it.
+type styled struct {
+	text  string
+	style string
+}
+
+const (
+	bold  = "\x1b[1m"
+	faint = "\x1b[2m"
+)
+
+// preview returns the lines describing a session: its title, stats and
+// first prompts.
+func (p *picker) preview(s session.SessionInfo) []styled {
+	lines := []styled{{"  " + s.Title, bold}}
+
+	var facts []string
+	if from := formatTime(s.Timestamp, "Jan 02, 2006 15:04"); from != "" {
+		if to := formatTime(s.LastTime, "Jan 02, 2006 15:04"); to != "" && to != from {
+			from += " – " + to
+		}
+		facts = append(facts, from)
+	}
+	facts = append(facts, fmt.Sprintf("%d prompts, %d replies", s.UserCount, s.ReplyCount))
+	if s.Model != "" {
+		facts = append(facts, s.Model)
+	}
+	if s.GitBranch != "" {
+		facts = append(facts, "branch "+s.GitBranch)
+	}
+	lines = append(lines, styled{"  " + strings.Join(facts, " · "), faint})
+	if s.CWD != "" {
+		lines = append(lines, styled{"  " + s.CWD, faint})
+	}
+	lines = append(lines, styled{})
+
+	prompts, ok := p.prompts[s.FilePath]
+	if !ok {
+		prompts = firstPrompts(s.FilePath, maxPrompts)
+		p.prompts[s.FilePath] = prompts
+	}
+	for _, prompt := range prompts {
+		lines = append(lines, styled{"  › " + prompt, ""})
+	}
+	return lines
+}
+
+// firstPrompts returns the first line of each of the first n prompts of a
+// transcript, reading only the start of it. Unreadable transcripts have
+// none.
+func firstPrompts(path string, n int) []string {
+	prompts, _ := parser.FirstPrompts(path, n)
+	var out []string
+	for _, m := range prompts {
+		line := "(image)"
+	texts:
+		for _, text := range m.Texts {
+			for _, l := range strings.Split(text, "\n") {
+				if l = strings.TrimSpace(l); l != "" {
+					line = l
+					break texts
+				}
+			}
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// formatTime formats an ISO 8601 timestamp in local time with layout, or
+// returns "" if it cannot be parsed.
+func formatTime(ts, layout string) string {
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return ""
+	}
+	return t.Local().Format(layout)
+}
+
+// fit cuts s to width characters, ending with an ellipsis if it was cut.
+// Control characters are removed first, since titles and prompts come from
+// transcripts and must not move the cursor or restyle the screen.
+func fit(s string, width int) string {
+	s = printable(s)
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:max(width-1, 0)]) + "…"
+}
+
+// printable removes C0 and C1 control characters other than tab from s.
+// Invalid UTF-8 becomes U+FFFD, so no stray byte can start an escape
+// sequence either.
+func printable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '\t' && (r < 0x20 || r >= 0x7f && r <= 0x9f) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// dim returns s in faint text.
+func dim(s string) string {
+	return faint + s + "\x1b[0m"
+}
@@ -0,0 +1,141 @@
+package pricing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HabibPro1999/shiplog/internal/parser"
+)
+
+// Price is the cost of a model in USD per million tokens.
+type Price struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheWrite float64 `json:"cache_write"`
+	CacheRead  float64 `json:"cache_read"`
+}
+
+// Cost returns the estimated USD cost of the given usage.
+func (p Price) Cost(u parser.TokenUsage) float64 {
+	return (float64(u.Input)*p.Input +
+		float64(u.Output)*p.Output +
+		float64(u.CacheCreation)*p.CacheWrite +
+		float64(u.CacheRead)*p.CacheRead) / 1_000_000
+}
+
+// Table maps model families to prices. A family such as "claude-opus-4-5"
+// prices its own model ids, with or without a date suffix, but not those of
+// later models such as "claude-opus-4-6"; models with no family are left
+// unpriced rather than guessed.
+type Table map[string]Price
+
+// Default returns the built-in list prices for Claude models.
+func Default() Table {
+	return Table{
+		"claude-opus-4-6":   {Input: 5, Output: 25, CacheWrite: 6.25, CacheRead: 0.50},
+		"claude-opus-4-5":   {Input: 5, Output: 25, CacheWrite: 6.25, CacheRead: 0.50},
+		"claude-opus-4-1":   {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+		"claude-opus-4":     {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+		"claude-sonnet-4-6": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+		"claude-sonnet-4-5": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+		"claude-sonnet-4":   {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+		"claude-haiku-4-5":  {Input: 1, Output: 5, CacheWrite: 1.25, CacheRead: 0.10},
+		"claude-3-7-sonnet": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+		"claude-3-5-sonnet": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+		"claude-3-5-haiku":  {Input: 0.80, Output: 4, CacheWrite: 1, CacheRead: 0.08},
+		"claude-3-opus":     {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+		"claude-3-haiku":    {Input: 0.25, Output: 1.25, CacheWrite: 0.30, CacheRead: 0.03},
+	}
+}
+
+// DefaultPath returns the location of the user's pricing file,
+// e.g. ~/.config/shiplog/pricing.json.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "shiplog", "pricing.json")
+}
+
+// Load returns the default table overlaid with the prices in a JSON file of
+// the form {"claude-opus-4-5": {"input": 5, "output": 25, ...}}. An empty
+// path, or a missing file at DefaultPath, yields the defaults.
+func Load(path string) (Table, error) {
+	table := Default()
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath()
+		if path == "" {
+			return table, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return table, nil
+		}
+		return nil, err
+	}
+	var overrides Table
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for model, price := range overrides {
+		table[model] = price
+	}
+	return table, nil
+}
+
+// Lookup returns the price for a model id. The id matches a family equal
+// to it, or to it without its version suffix: a date such as "-20250514",
+// "-latest", or a context tag such as "[1m]".
+func (t Table) Lookup(model string) (Price, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+	family := model
+	if i := strings.IndexByte(family, '['); i > 0 {
+		family = family[:i]
+	}
+	if i := strings.LastIndexByte(family, '-'); i > 0 && isVersion(family[i+1:]) {
+		family = family[:i]
+	}
+	price, ok := t[family]
+	return price, ok
+}
+
+// isVersion reports whether s is a model id's version suffix: "latest" or
+// an eight-digit date.
+func isVersion(s string) bool {
+	if s == "latest" {
+		return true
+	}
+	if len(s) != 8 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Estimate fills in the cost of each model's usage in meta.
+func (t Table) Estimate(meta *parser.SessionMeta) {
+	for i := range meta.Usage {
+		u := &meta.Usage[i]
+		price, ok := t.Lookup(u.Model)
+		u.Priced = ok
+		u.Cost = 0
+		if ok {
+			u.Cost = price.Cost(u.Tokens)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package redact
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/HabibPro1999/shiplog/internal/session"
+)
+
+// Identity holds the machine-specific names that an Anonymizer hides.
+type Identity struct {
+	Home     string // home directory, e.g. /Users/mohamed
+	User     string // login name
+	Hostname string
+}
+
+// CurrentIdentity returns the identity of the user running shiplog. Names
+// that cannot be determined are left empty.
+func CurrentIdentity() Identity {
+	var id Identity
+	id.Home, _ = os.UserHomeDir()
+	if u, err := user.Current(); err == nil {
+		id.User = u.Username
+	}
+	id.Hostname, _ = os.Hostname()
+	return id
+}
+
+// Anonymizer replaces the home directory, user name, host name and a list of
+// internal names with neutral ones. The same name always gets the same
+// replacement, so paths stay consistent across a page.
+type Anonymizer struct {
+	rules []anonRule
+}
+
+type anonRule struct {
+	pattern *regexp.Regexp
+	with    string
+}
+
+// NewAnonymizer returns an Anonymizer for id and the internal names in
+// names, which map each name to its replacement. Internal names are matched
+// as whole words, ignoring case, and take precedence over the identity.
+func NewAnonymizer(id Identity, names map[string]string) *Anonymizer {
+	a := &Anonymizer{}
+
+	keys := make([]string, 0, len(names))
+	for k := range names {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	// Longer names first, so that "acme-corp" wins over "acme".
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		a.add("(?i)"+word(k), names[k])
+	}
+
+	if home := filepath.Clean(id.Home); id.Home != "" && home != "/" {
+		anon := filepath.Join(filepath.Dir(home), "user")
+		a.add(regexp.QuoteMeta(home)+`\b`, anon)
+		// Claude's project dirs encode the path, turning /Users/first.last
+		// into -Users-first-last, and projects are shown decoded from
+		// those as Users/first/last.
+		homeDir, anonDir := session.PathToProjectDir(home), session.PathToProjectDir(anon)
+		a.add(word(homeDir), anonDir)
+		a.add(word(session.ProjectName(homeDir)), session.ProjectName(anonDir))
+	}
+	if id.Hostname != "" {
+		a.add(word(id.Hostname), "host")
+		if short, _, ok := strings.Cut(id.Hostname, "."); ok && short != "" {
+			a.add(word(short), "host")
+		}
+	}
+	if id.User != "" && !genericUsers[id.User] {
+		a.add(word(id.User), "user")
+	}
+	return a
+}
+
+// genericUsers are account names that identify no one and are common words
+// ("the project root"), so they are left alone.
+var genericUsers = map[string]bool{"root": true, "admin": true, "user": true, "ubuntu": true, "ec2-user": true}
+
+func (a *Anonymizer) add(expr, with string) {
+	a.rules = append(a.rules, anonRule{pattern: regexp.MustCompile(expr), with: with})
+}
+
+// word returns a pattern matching s literally, not as part of a longer word.
+func word(s string) string {
+	expr := regexp.QuoteMeta(s)
+	if isWordChar(s[0]) {
+		expr = `\b` + expr
+	}
+	if isWordChar(s[len(s)-1]) {
+		expr += `\b`
+	}
+	return expr
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// String returns s with every known name replaced.
+func (a *Anonymizer) String(s string) string {
+	for _, r := range a.rules {
+		s = r.pattern.ReplaceAllLiteralString(s, r.with)
+	}
+	return s
+}
+
+// Rules returns each pattern and its replacement, in order.
+func (a *Anonymizer) Rules() []string {
+	out := make([]string, len(a.rules))
+	for i, r := range a.rules {
+		out[i] = r.pattern.String() + " -> " + r.with
+	}
+	return out
+}
+
+// AnonymizePath returns the location of the user's internal names file.
+func AnonymizePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "shiplog", "anonymize.json")
+}
+
+// LoadNames reads the internal names to anonymize from a JSON file of the
+// form {"names": {"acme": "example", "Project Falcon": "the project"}}. An
+// empty path, or a missing file at AnonymizePath, yields no names.
+func LoadNames(path string) (map[string]string, error) {
+	explicit := path != ""
+	if !explicit {
+		path = AnonymizePath()
+		if path == "" {
+			return nil, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var cfg struct {
+		Names map[string]string `json:"names"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg.Names, nil
+}
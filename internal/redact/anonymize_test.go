@@ -0,0 +1,100 @@
+package redact
+
+import "testing"
+
+func TestAnonymizer(t *testing.T) {
+	tests := []struct {
+		name  string
+		id    Identity
+		names map[string]string
+		in    string
+		want  string
+	}{
+		{
+			name: "home path",
+			id:   Identity{Home: "/home/alice", User: "alice"},
+			in:   "cat /home/alice/src/app/main.go",
+			want: "cat /home/user/src/app/main.go",
+		},
+		{
+			name: "home prefix of a longer name",
+			id:   Identity{Home: "/home/al"},
+			in:   "/home/alice/x",
+			want: "/home/alice/x",
+		},
+		{
+			name: "project dir",
+			id:   Identity{Home: "/home/alice"},
+			in:   "~/.claude/projects/-home-alice-src-app/1.jsonl",
+			want: "~/.claude/projects/-home-user-src-app/1.jsonl",
+		},
+		{
+			name: "project name",
+			id:   Identity{Home: "/home/alice"},
+			in:   "Project: home/alice/src/app",
+			want: "Project: home/user/src/app",
+		},
+		{
+			name: "dotted login in project dir",
+			id:   Identity{Home: "/Users/first.last", User: "first.last"},
+			in:   "-Users-first-last-src-app",
+			want: "-Users-user-src-app",
+		},
+		{
+			name: "dotted login in project name",
+			id:   Identity{Home: "/Users/first.last", User: "first.last"},
+			in:   "Users/first/last/src/app",
+			want: "Users/user/src/app",
+		},
+		{
+			name: "underscored login in project dir",
+			id:   Identity{Home: "/home/first_last", User: "first_last"},
+			in:   "-home-first-last-src-app",
+			want: "-home-user-src-app",
+		},
+		{
+			name: "underscored login in project name",
+			id:   Identity{Home: "/home/first_last", User: "first_last"},
+			in:   "home/first/last/src/app",
+			want: "home/user/src/app",
+		},
+		{
+			name: "project dir of another user",
+			id:   Identity{Home: "/home/first_last"},
+			in:   "-home-first-lastname-app",
+			want: "-home-first-lastname-app",
+		},
+		{
+			name: "user name as a word",
+			id:   Identity{User: "alice"},
+			in:   "alice ran it, not malice",
+			want: "user ran it, not malice",
+		},
+		{
+			name: "generic user name",
+			id:   Identity{User: "root"},
+			in:   "the project root",
+			want: "the project root",
+		},
+		{
+			name: "host name and short host name",
+			id:   Identity{Hostname: "box.corp.example.com"},
+			in:   "ssh box.corp.example.com; ping box",
+			want: "ssh host; ping host",
+		},
+		{
+			name:  "internal names, longest first and ignoring case",
+			names: map[string]string{"acme": "example", "Acme Cloud": "the cloud"},
+			in:    "ACME CLOUD and acme",
+			want:  "the cloud and example",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewAnonymizer(tt.id, tt.names).String(tt.in)
+			if got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+// Package redact removes secrets and identifying names from a conversation
+// before it is rendered.
+package redact
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/HabibPro1999/shiplog/internal/parser"
+)
+
+// Detector finds one kind of sensitive value. If Pattern has a capture
+// group, only the first group is replaced, so that a pattern can match
+// "password=..." and keep "password=".
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Check   func(match string) bool // optional filter on the replaced text
+}
+
+// Builtin returns the built-in detectors, most specific first.
+func Builtin() []Detector {
+	return []Detector{
+		{Name: "private-key", Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+		{Name: "aws-access-key", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{Name: "aws-secret-key", Pattern: regexp.MustCompile(`(?i)aws_secret_access_key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`)},
+		{Name: "github-token", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+		{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+		// KEY=value lines of .env files and shell exports, then quoted
+		// values of JSON and YAML keys. Keys such as "tokenizer" match too,
+		// so the value must look like a secret.
+		{Name: "secret-assignment", Pattern: regexp.MustCompile(`\b[A-Z0-9_]*(?:API_?KEY|SECRET|TOKEN|PASSWORD|PASSWD)[A-Z0-9_]*\s*[=:]\s*["']?([^\s"'\[][^\s"']{7,})`), Check: secretValue},
+		{Name: "secret-assignment", Pattern: regexp.MustCompile(`(?i)\b[a-z0-9_-]*(?:api[_-]?key|secret|token|password|passwd)["']?\s*:\s*["']([^\s"'\[][^\s"']{7,})["']`), Check: secretValue},
+		{Name: "email", Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+		{Name: "high-entropy", Pattern: regexp.MustCompile(`\b[A-Za-z0-9_+-]{32,}={0,2}`), Check: highEntropy},
+	}
+}
+
+// highEntropy reports whether s looks like a random token: it mixes upper
+// and lower case letters and digits, and its characters are spread widely.
+// Hex digests and identifiers fall below the threshold.
+func highEntropy(s string) bool {
+	upper, lower, digit, _ := classes(s)
+	return upper && lower && digit && entropy(s) >= 4.3
+}
+
+// secretValue reports whether an assigned value looks like a secret rather
+// than a setting such as "cl100k_base": it mixes at least three of upper
+// case, lower case, digits and symbols other than "_", "-" and ".", or it is
+// long, contains both letters and digits and has spread-out characters.
+func secretValue(s string) bool {
+	upper, lower, digit, symbol := classes(s)
+	n := 0
+	for _, c := range []bool{upper, lower, digit, symbol} {
+		if c {
+			n++
+		}
+	}
+	if n >= 3 {
+		return true
+	}
+	return len(s) >= 16 && (upper || lower) && digit && entropy(s) >= 3.5
+}
+
+// classes reports which kinds of characters s contains. The separators of
+// identifiers, "_", "-" and ".", are not symbols.
+func classes(s string) (upper, lower, digit, symbol bool) {
+	for _, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r != '_' && r != '-' && r != '.':
+			symbol = true
+		}
+	}
+	return upper, lower, digit, symbol
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var e float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+// Config is the user's redaction file, e.g. ~/.config/shiplog/redact.json:
+//
+//	{"patterns": [{"name": "internal-host", "regex": "\\b[a-z0-9-]+\\.corp\\.example\\.com\\b"}],
+//	 "disable": ["email"]}
+type Config struct {
+	Patterns []struct {
+		Name  string `json:"name"`
+		Regex string `json:"regex"`
+	} `json:"patterns"`
+	Disable []string `json:"disable"` // names of built-in detectors to turn off
+}
+
+// DefaultPath returns the location of the user's redaction file.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "shiplog", "redact.json")
+}
+
+// Load returns the built-in detectors adjusted by the config file at path:
+// disabled detectors are removed and user patterns are added after the
+// specific built-in ones, before the high-entropy check. An empty path, or a
+// missing file at DefaultPath, yields the built-in detectors.
+func Load(path string) ([]Detector, error) {
+	detectors := Builtin()
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath()
+		if path == "" {
+			return detectors, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return detectors, nil
+		}
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	disabled := make(map[string]bool)
+	for _, name := range cfg.Disable {
+		disabled[name] = true
+	}
+	var custom []Detector
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("%s: pattern %q: %w", path, p.Name, err)
+		}
+		// A pattern that matches nothing would insert a placeholder
+		// between every two characters.
+		if re.MatchString("") {
+			return nil, fmt.Errorf("%s: pattern %q matches the empty string", path, p.Name)
+		}
+		name := p.Name
+		if name == "" {
+			name = "custom"
+		}
+		custom = append(custom, Detector{Name: name, Pattern: re})
+	}
+
+	var out []Detector
+	for _, d := range detectors {
+		if d.Name == "high-entropy" {
+			out = append(out, custom...)
+			custom = nil
+		}
+		if !disabled[d.Name] {
+			out = append(out, d)
+		}
+	}
+	return append(out, custom...), nil
+}
+
+// Redactor applies detectors and counts what they replaced.
+type Redactor struct {
+	detectors []Detector
+	counts    map[string]int
+}
+
+// New returns a Redactor using detectors in order.
+func New(detectors []Detector) *Redactor {
+	return &Redactor{detectors: detectors, counts: make(map[string]int)}
+}
+
+// Patterns returns the name and expression of each detector, in order.
+func (r *Redactor) Patterns() []string {
+	out := make([]string, len(r.detectors))
+	for i, d := range r.detectors {
+		out[i] = d.Name + " " + d.Pattern.String()
+	}
+	return out
+}
+
+// Placeholder returns the text that replaces a value found by a detector.
+func Placeholder(name string) string {
+	return "[REDACTED:" + name + "]"
+}
+
+// String returns s with every detected value replaced by its placeholder.
+func (r *Redactor) String(s string) string {
+	for _, d := range r.detectors {
+		s = r.apply(d, s)
+	}
+	return s
+}
+
+func (r *Redactor) apply(d Detector, s string) string {
+	matches := d.Pattern.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	var b []byte
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		if d.Check != nil && !d.Check(s[start:end]) {
+			continue
+		}
+		b = append(b, s[last:start]...)
+		b = append(b, Placeholder(d.Name)...)
+		last = end
+		r.counts[d.Name]++
+	}
+	if last == 0 {
+		return s
+	}
+	return string(append(b, s[last:]...))
+}
+
+// Messages returns a copy of messages with text, reasoning, tool inputs and
+// tool results redacted, including subagent conversations and abandoned
+// branches. Images are kept as they are.
+func (r *Redactor) Messages(messages []parser.Message) []parser.Message {
+	return Rewrite(messages, r.String)
+}
+
+// Count is the number of values a detector replaced.
+type Count struct {
+	Name  string
+	Count int
+}
+
+// Summary returns the replacements made so far, most frequent first.
+func (r *Redactor) Summary() []Count {
+	var out []Count
+	for name, n := range r.counts {
+		out = append(out, Count{Name: name, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
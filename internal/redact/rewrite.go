@@ -0,0 +1,117 @@
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/HabibPro1999/shiplog/internal/parser"
+)
+
+// Rewrite returns a copy of messages with f applied to their text,
+// reasoning, tool inputs and tool results, including subagent conversations
+// and abandoned branches. Images are kept as they are.
+func Rewrite(messages []parser.Message, f func(string) string) []parser.Message {
+	if messages == nil {
+		return nil
+	}
+	out := make([]parser.Message, len(messages))
+	for i, msg := range messages {
+		msg.Texts = rewriteAll(msg.Texts, f)
+		msg.Thinking = rewriteAll(msg.Thinking, f)
+		if msg.Tools != nil {
+			tools := make([]parser.ToolCall, len(msg.Tools))
+			for j, call := range msg.Tools {
+				call.SetInput(rewriteJSON(call.Input, f))
+				call.Result = f(call.Result)
+				call.Subagent = Rewrite(call.Subagent, f)
+				tools[j] = call
+			}
+			msg.Tools = tools
+		}
+		if msg.Branches != nil {
+			branches := make([][]parser.Message, len(msg.Branches))
+			for j, branch := range msg.Branches {
+				branches[j] = Rewrite(branch, f)
+			}
+			msg.Branches = branches
+		}
+		out[i] = msg
+	}
+	return out
+}
+
+// rewriteAll returns a copy of ss with f applied to each element.
+func rewriteAll(ss []string, f func(string) string) []string {
+	if ss == nil {
+		return nil
+	}
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[i] = f(s)
+	}
+	return out
+}
+
+// rewriteJSON applies f to the string values of a tool input. Each changed
+// value is replaced within the original text, which keeps the input's key
+// order and formatting; if a value cannot be found there, the input is
+// re-encoded.
+func rewriteJSON(raw json.RawMessage, f func(string) string) json.RawMessage {
+	if len(raw) == 0 {
+		return raw
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return json.RawMessage(f(string(raw)))
+	}
+	var changed [][2]string
+	v = rewriteValue(v, f, &changed)
+	if len(changed) == 0 {
+		return raw
+	}
+
+	out := string(raw)
+	for _, c := range changed {
+		from, to := quote(c[0]), quote(c[1])
+		if !strings.Contains(out, from) {
+			if data, err := json.Marshal(v); err == nil {
+				return data
+			}
+			break
+		}
+		out = strings.ReplaceAll(out, from, to)
+	}
+	return json.RawMessage(out)
+}
+
+// rewriteValue applies f to the strings within a decoded JSON value,
+// recording each string that changed along with its replacement.
+func rewriteValue(v any, f func(string) string, changed *[][2]string) any {
+	switch v := v.(type) {
+	case string:
+		rewritten := f(v)
+		if rewritten != v {
+			*changed = append(*changed, [2]string{v, rewritten})
+		}
+		return rewritten
+	case []any:
+		for i := range v {
+			v[i] = rewriteValue(v[i], f, changed)
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = rewriteValue(v[k], f, changed)
+		}
+	}
+	return v
+}
+
+// quote returns s as a JSON string literal, escaped the way tool inputs are
+// written.
+func quote(s string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,112 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// gfm converts GitHub-flavored Markdown to HTML, highlighting fenced code
+// blocks. Raw HTML in the source is omitted and links with dangerous schemes
+// (javascript:, data: other than images) are dropped, since goldmark runs
+// without WithUnsafe.
+var gfm = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		extension.NewFootnote(extension.WithFootnoteIDPrefixFunction(footnotePrefix)),
+	),
+	goldmark.WithParserOptions(
+		parser.WithASTTransformers(util.Prioritized(pageTransformer{}, 1000)),
+	),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(util.Prioritized(codeBlockRenderer{}, 100)),
+	),
+)
+
+// footnotePrefixKey carries the footnote id prefix of one conversion, so
+// that footnotes of different messages on the same page do not collide.
+var footnotePrefixKey = parser.NewContextKey()
+
+// footnotePrefix returns the id prefix recorded on a node's document.
+func footnotePrefix(n ast.Node) []byte {
+	if v, ok := n.OwnerDocument().AttributeString("footnote-prefix"); ok {
+		return v.([]byte)
+	}
+	return nil
+}
+
+// pageTransformer adapts documents to the exported page: links open in a new
+// tab, since following one would navigate away from the standalone page, and
+// the footnote prefix is recorded for footnotePrefix.
+type pageTransformer struct{}
+
+func (pageTransformer) Transform(doc *ast.Document, _ text.Reader, pc parser.Context) {
+	if prefix, ok := pc.Get(footnotePrefixKey).(string); ok {
+		doc.SetAttributeString("footnote-prefix", []byte(prefix))
+	}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if link, ok := n.(*ast.Link); ok && bytes.HasPrefix(link.Destination, []byte("#")) {
+			return ast.WalkContinue, nil
+		}
+		switch n.Kind() {
+		case ast.KindLink, ast.KindAutoLink:
+			n.SetAttributeString("target", "_blank")
+			n.SetAttributeString("rel", "noopener")
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// markdownHTML renders message text as sanitized HTML, falling back to
+// escaped text if rendering fails. idPrefix keeps footnote ids unique
+// within the page.
+func markdownHTML(s, idPrefix string) template.HTML {
+	var buf bytes.Buffer
+	pc := parser.NewContext()
+	pc.Set(footnotePrefixKey, idPrefix)
+	if err := gfm.Convert([]byte(s), &buf, parser.WithContext(pc)); err != nil {
+		return template.HTML("<p>" + html.EscapeString(s) + "</p>")
+	}
+	return template.HTML(buf.String())
+}
+
+// codeBlockRenderer renders fenced code blocks with syntax highlighting.
+type codeBlockRenderer struct{}
+
+func (codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, renderFencedCode)
+}
+
+func renderFencedCode(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	block := n.(*ast.FencedCodeBlock)
+	lang := string(block.Language(source))
+	var code bytes.Buffer
+	lines := block.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		code.Write(seg.Value(source))
+	}
+	if lang == "" {
+		w.WriteString("<pre><code>")
+	} else {
+		fmt.Fprintf(w, `<pre><code class="language-%s chroma">`, html.EscapeString(lang))
+	}
+	w.WriteString(string(highlight(code.String(), lang)))
+	w.WriteString("</code></pre>\n")
+	return ast.WalkSkipChildren, nil
+}
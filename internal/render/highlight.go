@@ -0,0 +1,167 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// tokenCSS returns a rule per token class whose style differs from plain
+// text. Backgrounds are left to the page's own code blocks.
+func tokenCSS(style *chroma.Style) string {
+	text := style.Get(chroma.Text)
+	var rules []string
+	for ttype, class := range chroma.StandardTypes {
+		if class == "" || ttype == chroma.Background {
+			continue
+		}
+		e := style.Get(ttype)
+		var decl []string
+		if e.Colour.IsSet() && e.Colour != text.Colour {
+			decl = append(decl, "color:"+e.Colour.String())
+		}
+		if e.Bold == chroma.Yes {
+			decl = append(decl, "font-weight:bold")
+		}
+		if e.Italic == chroma.Yes {
+			decl = append(decl, "font-style:italic")
+		}
+		if e.Underline == chroma.Yes {
+			decl = append(decl, "text-decoration:underline")
+		}
+		if len(decl) > 0 {
+			rules = append(rules, fmt.Sprintf(".chroma .%s{%s}", class, strings.Join(decl, ";")))
+		}
+	}
+	sort.Strings(rules)
+	return strings.Join(rules, "\n")
+}
+
+// lexerFor returns the lexer for a language name or alias, or nil if there
+// is none.
+func lexerFor(lang string) chroma.Lexer {
+	if lang == "" {
+		return nil
+	}
+	l := lexers.Get(lang)
+	if l == nil {
+		return nil
+	}
+	return chroma.Coalesce(l)
+}
+
+// highlightLines tokenises code as lang and returns the HTML of each line,
+// without trailing newlines. ok is false if lang has no lexer or the code
+// cannot be tokenised.
+func highlightLines(code, lang string) (lines []template.HTML, ok bool) {
+	lexer := lexerFor(lang)
+	if lexer == nil {
+		return nil, false
+	}
+	it, err := lexer.Tokenise(nil, code)
+	if err != nil {
+		return nil, false
+	}
+	for _, tokens := range chroma.SplitTokensIntoLines(it.Tokens()) {
+		var b strings.Builder
+		for _, t := range tokens {
+			value := strings.TrimSuffix(t.Value, "\n")
+			if value == "" {
+				continue
+			}
+			class := tokenClass(t.Type)
+			if class == "" {
+				b.WriteString(html.EscapeString(value))
+				continue
+			}
+			fmt.Fprintf(&b, `<span class="%s">%s</span>`, class, html.EscapeString(value))
+		}
+		lines = append(lines, template.HTML(b.String()))
+	}
+	// Lexers that ensure a trailing newline add an empty last line.
+	if want := strings.Count(code, "\n") + 1; len(lines) > want {
+		lines = lines[:want]
+	}
+	return lines, true
+}
+
+// tokenClass returns the CSS class of a token type, falling back to its
+// sub-category and category.
+func tokenClass(t chroma.TokenType) string {
+	if t == chroma.Whitespace {
+		return ""
+	}
+	for _, tt := range []chroma.TokenType{t, t.SubCategory(), t.Category()} {
+		if class, ok := chroma.StandardTypes[tt]; ok && tt != chroma.Text {
+			return class
+		}
+	}
+	return ""
+}
+
+// highlight returns code as highlighted HTML, or escaped text if lang has
+// no lexer.
+func highlight(code, lang string) template.HTML {
+	lines, ok := highlightLines(code, lang)
+	if !ok {
+		return template.HTML(html.EscapeString(code))
+	}
+	joined := make([]string, len(lines))
+	for i, l := range lines {
+		joined[i] = string(l)
+	}
+	out := strings.Join(joined, "\n")
+	if strings.HasSuffix(code, "\n") && !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	return template.HTML(out)
+}
+
+// numberedLine matches a line of Read output: a right-aligned line number,
+// a tab or arrow, then the file's content.
+var numberedLine = regexp.MustCompile(`^(\s*\d+(?:\t|→))(.*)$`)
+
+// highlightNumbered highlights Read output in the language of the file,
+// keeping its line numbers. Lines that are not numbered, such as a
+// truncation note, are kept as plain text.
+func highlightNumbered(output, lang string) template.HTML {
+	if lexerFor(lang) == nil {
+		return template.HTML(html.EscapeString(output))
+	}
+	rows := strings.Split(output, "\n")
+	var code []string
+	prefixes := make([]string, len(rows))
+	numbered := make([]bool, len(rows))
+	for i, row := range rows {
+		if m := numberedLine.FindStringSubmatch(row); m != nil {
+			prefixes[i] = m[1]
+			numbered[i] = true
+			code = append(code, m[2])
+		}
+	}
+	lines, ok := highlightLines(strings.Join(code, "\n"), lang)
+	if !ok || len(lines) != len(code) {
+		return template.HTML(html.EscapeString(output))
+	}
+
+	var b strings.Builder
+	next := 0
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		if !numbered[i] {
+			b.WriteString(html.EscapeString(row))
+			continue
+		}
+		fmt.Fprintf(&b, `<span class="ln">%s</span>%s`, html.EscapeString(prefixes[i]), lines[next])
+		next++
+	}
+	return template.HTML(b.String())
+}
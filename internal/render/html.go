@@ -3,97 +3,569 @@ package render
 import (
 	"bytes"
 	"embed"
+	"encoding/json"
 	"fmt"
 	"html"
 	"html/template"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/HabibPro1999/shiplog/internal/diff"
 	"github.com/HabibPro1999/shiplog/internal/parser"
 )
 
-//go:embed template/chat.html
+//go:embed template/chat.html template/index.html template/themes/*.css
 var tmplFS embed.FS
 
+// Thinking display modes for Options.Thinking.
+const (
+	ThinkingHide      = "hide"
+	ThinkingCollapsed = "collapsed"
+	ThinkingShow      = "show"
+)
+
+// Export formats.
+const (
+	FormatHTML     = "html"
+	FormatMarkdown = "md"
+	FormatJSON     = "json"
+)
+
+// Options controls optional parts of the rendered page.
+type Options struct {
+	Thinking string // ThinkingHide (default), ThinkingCollapsed or ThinkingShow
+	AssetDir string // Markdown: directory for image files, relative to the document; empty inlines them
+	Theme    string // HTML: one of Themes(); empty means ThemeAuto
+	Template string // HTML: user template directory with chat.html and/or .css files
+	IndexURL string // HTML: link back to the archive index of a batch export; empty for a standalone page
+}
+
 // TemplateMessage is the pre-processed message for the template.
 type TemplateMessage struct {
-	Role      string
-	Texts     []template.HTML // HTML-escaped text (for JS to unescape and render markdown)
-	Images    []parser.Image
-	ToolLabel string // pre-computed: "Read file" or "5 tool actions performed"
+	Role         string
+	Anchor       string          // element id derived from the entry uuid, stable across exports; may be empty
+	Speaker      string          // label above the message: "You", "Claude"
+	Texts        []template.HTML // user: escaped text; assistant: Markdown rendered to HTML
+	Images       []parser.Image
+	Thinking     []string // assistant reasoning, empty when hidden
+	ThinkingOpen bool     // render the reasoning expanded
+	ToolLabel    string   // pre-computed: "Read file" or "5 tool actions performed"
+	Tools        []TemplateTool
+	Tokens       string              // assistant: "12.4k in · 350 out"
+	ForkLabel    string              // pre-computed: "2 abandoned branches"
+	Branches     [][]TemplateMessage // fork: abandoned alternatives
+	Timestamp    string              // pre-formatted
+}
+
+// TemplateTool is a pre-processed tool call for the expandable tool panels.
+type TemplateTool struct {
+	Label   string // human-readable tool name: "Run command"
+	Summary string // what it operated on: the command, pattern or path
+	Detail  string // annotation: "12 matches", "lines 1–40"
+	Input   string // command text for Bash, indented JSON for other tools
+	Output  string // tool_result text, truncated
+	IsError bool
+	Lang    string               // language of the file read or edited
+	Hunks   [][]TemplateDiffLine // Edit/MultiEdit/Write: one diff per replacement
+
+	InputHTML  template.HTML // Input, syntax highlighted
+	OutputHTML template.HTML // Output, highlighted for Read calls of known languages
+
+	Subagent []TemplateMessage // Task: the subagent's own conversation
+}
+
+// TemplateDiffLine is one line of an Edit or Write diff.
+type TemplateDiffLine struct {
+	Class string // "add", "del" or "ctx"
+	Sign  string // "+", "-" or " "
+	Text  string
+	HTML  template.HTML // Text, syntax highlighted in the file's language
+}
+
+// TemplateOutlineItem is a user prompt listed in the sidebar's outline.
+type TemplateOutlineItem struct {
+	Turn      int    // 1-based position among the session's prompts
+	Anchor    string // id of the prompt's message block
+	Label     string // first line of the prompt, truncated
 	Timestamp string // pre-formatted
 }
 
+// TemplateUsage is a row of the sidebar's usage panel.
+type TemplateUsage struct {
+	Model  string // model id
+	Tokens string // "1.2M tokens"
+	Detail string // "12.4k in · 3.1k out · 1.1M cache read · 40k cache write"
+	Cost   string // "$0.42", or "—" when the model has no known price
+}
+
+// TemplateFileChange is a row of the sidebar's "Files changed" summary.
+type TemplateFileChange struct {
+	Path    string // relative to the session's working directory when possible
+	Added   int
+	Removed int
+}
+
 // TemplateData holds all data passed to the HTML template.
 type TemplateData struct {
 	Title          string
+	IndexURL       string // link to the archive index, empty for a standalone page
 	Project        string
 	DateRange      string
 	Model          string
 	UserCount      int
 	AssistantCount int
+	FilesChanged   []TemplateFileChange
+	Usage          []TemplateUsage
+	TotalTokens    string
+	TotalCost      string
 	Messages       []TemplateMessage
+	Outline        []TemplateOutlineItem // top-level user prompts, in order
+	Theme          string                // theme name, e.g. "dark"
+	ThemeCSS       template.CSS          // the theme's CSS variables and rules
+	HighlightCSS   template.CSS          // token colours for highlighted code
+	CustomCSS      template.CSS          // .css files of the user template directory
 }
 
 // Generate renders messages and metadata into a self-contained HTML page.
-func Generate(messages []parser.Message, meta parser.SessionMeta, project string) ([]byte, error) {
-	funcMap := template.FuncMap{
-		"safeHTML": func(s template.HTML) template.HTML { return s },
+func Generate(messages []parser.Message, meta parser.SessionMeta, project string, opts Options) ([]byte, error) {
+	theme := opts.Theme
+	if theme == "" {
+		theme = ThemeAuto
 	}
-
-	tmpl, err := template.New("chat.html").Funcs(funcMap).ParseFS(tmplFS, "template/chat.html")
+	themeStyles, err := themeCSS(theme)
 	if err != nil {
-		return nil, fmt.Errorf("parse template: %w", err)
+		return nil, err
 	}
+	tmpl, err := loadTemplate(opts.Template)
+	if err != nil {
+		return nil, err
+	}
+	customCSS, err := loadCustomCSS(opts.Template)
+	if err != nil {
+		return nil, fmt.Errorf("load template CSS: %w", err)
+	}
+
+	tmplMessages, userCount, assistantCount := buildTemplateMessages(messages, opts, new(int))
 
-	var tmplMessages []TemplateMessage
-	userCount := 0
-	assistantCount := 0
+	data := TemplateData{
+		Title:          meta.Title,
+		IndexURL:       opts.IndexURL,
+		Project:        project,
+		DateRange:      meta.DateRange,
+		Model:          meta.Model,
+		UserCount:      userCount,
+		AssistantCount: assistantCount,
+		FilesChanged:   buildFileChanges(parser.FileChanges(messages), meta.CWD),
+		Usage:          buildUsage(meta.Usage),
+		Messages:       tmplMessages,
+		Outline:        buildOutline(messages),
+		Theme:          theme,
+		ThemeCSS:       themeStyles,
+		HighlightCSS:   codeCSS(theme),
+		CustomCSS:      customCSS,
+	}
+	if len(meta.Usage) > 0 {
+		data.TotalTokens = FormatTokens(meta.TotalTokens().Total()) + " tokens"
+		cost, priced := meta.TotalCost()
+		data.TotalCost = formatCost(cost, priced)
+	}
 
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("execute template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// buildTemplateMessages converts parsed messages into template messages and
+// counts the user and assistant messages that will be shown. seq numbers
+// the assistant messages across the whole document, subagents and branches
+// included, for footnote ids.
+func buildTemplateMessages(messages []parser.Message, opts Options, seq *int) (tmplMessages []TemplateMessage, userCount, assistantCount int) {
 	for _, msg := range messages {
 		tm := TemplateMessage{
 			Role:      msg.Role,
+			Anchor:    messageAnchor(msg),
+			Speaker:   "Claude",
 			Timestamp: formatTimestamp(msg.Timestamp),
 		}
 
 		switch msg.Role {
 		case "user":
 			userCount++
+			tm.Speaker = "You"
 			for _, t := range msg.Texts {
 				tm.Texts = append(tm.Texts, template.HTML(html.EscapeString(t)))
 			}
 			tm.Images = msg.Images
 		case "assistant":
-			assistantCount++
-			for _, t := range msg.Texts {
-				tm.Texts = append(tm.Texts, template.HTML(html.EscapeString(t)))
+			*seq++
+			for i, t := range msg.Texts {
+				tm.Texts = append(tm.Texts, markdownHTML(t, footnoteID(*seq, i)))
+			}
+			if opts.Thinking == ThinkingCollapsed || opts.Thinking == ThinkingShow {
+				tm.Thinking = msg.Thinking
+				tm.ThinkingOpen = opts.Thinking == ThinkingShow
+			}
+			if len(tm.Texts) == 0 && len(tm.Thinking) == 0 {
+				continue
+			}
+			if len(tm.Texts) > 0 {
+				assistantCount++
 			}
+			tm.Tokens = tokenBadge(msg.Usage)
 		case "tool_group":
-			if len(msg.ToolNames) == 1 {
-				tm.ToolLabel = parser.ToolDisplayName(msg.ToolNames[0])
-			} else if len(msg.ToolNames) > 1 {
-				tm.ToolLabel = fmt.Sprintf("%d tool actions performed", len(msg.ToolNames))
+			tm.Tokens = tokenBadge(msg.Usage)
+			if len(msg.Tools) == 1 {
+				tm.ToolLabel = parser.ToolDisplayName(msg.Tools[0].Name)
+			} else if len(msg.Tools) > 1 {
+				tm.ToolLabel = fmt.Sprintf("%d tool actions performed", len(msg.Tools))
+			}
+			for _, call := range msg.Tools {
+				tm.Tools = append(tm.Tools, buildTemplateTool(call, opts, seq))
+			}
+		case "fork":
+			for _, branch := range msg.Branches {
+				tb, _, _ := buildTemplateMessages(branch, opts, seq)
+				tm.Branches = append(tm.Branches, tb)
+			}
+			tm.ForkLabel = "1 abandoned branch"
+			if len(tm.Branches) > 1 {
+				tm.ForkLabel = fmt.Sprintf("%d abandoned branches", len(tm.Branches))
 			}
 		}
 
 		tmplMessages = append(tmplMessages, tm)
 	}
+	return tmplMessages, userCount, assistantCount
+}
 
-	data := TemplateData{
-		Title:          meta.Title,
-		Project:        project,
-		DateRange:      meta.DateRange,
-		Model:          meta.Model,
-		UserCount:      userCount,
-		AssistantCount: assistantCount,
-		Messages:       tmplMessages,
+// CountMessages counts the user prompts and the assistant replies with
+// text, as shown in the header of every format.
+func CountMessages(messages []parser.Message) (userCount, assistantCount int) {
+	for _, m := range messages {
+		switch m.Role {
+		case "user":
+			userCount++
+		case "assistant":
+			if len(m.Texts) > 0 {
+				assistantCount++
+			}
+		}
 	}
+	return userCount, assistantCount
+}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return nil, fmt.Errorf("execute template: %w", err)
+// messageAnchor returns the element id of a message: its role's prefix and
+// the uuid of the entry it came from, so that links to a message keep
+// working when the session is exported again. Tool groups and forks get
+// their own prefixes because they may share a uuid with a neighbouring
+// message.
+func messageAnchor(msg parser.Message) string {
+	if msg.UUID == "" {
+		return ""
+	}
+	switch msg.Role {
+	case "tool_group":
+		return "tools-" + msg.UUID
+	case "fork":
+		return "fork-" + msg.UUID
+	default:
+		return "msg-" + msg.UUID
+	}
+}
+
+// Length of the prompt excerpts in the sidebar's outline, in characters.
+const maxOutlineLabel = 60
+
+// buildOutline lists the user prompts of the main conversation. Prompts
+// without a uuid cannot be linked to and are left out, but keep their turn.
+func buildOutline(messages []parser.Message) []TemplateOutlineItem {
+	var items []TemplateOutlineItem
+	turn := 0
+	for _, msg := range messages {
+		if msg.Role != "user" {
+			continue
+		}
+		turn++
+		anchor := messageAnchor(msg)
+		if anchor == "" {
+			continue
+		}
+		label := "(image)"
+		if len(msg.Texts) > 0 {
+			label, _, _ = strings.Cut(strings.TrimSpace(msg.Texts[0]), "\n")
+			if r := []rune(label); len(r) > maxOutlineLabel {
+				label = strings.TrimSpace(string(r[:maxOutlineLabel-1])) + "…"
+			}
+		}
+		items = append(items, TemplateOutlineItem{
+			Turn:      turn,
+			Anchor:    anchor,
+			Label:     label,
+			Timestamp: formatTimestamp(msg.Timestamp),
+		})
+	}
+	return items
+}
+
+// footnoteID returns the footnote id prefix for the i-th text of the n-th
+// assistant message. Numbering by position rather than by uuid keeps ids
+// unique even when uuids share a prefix or repeat across branches.
+func footnoteID(n, i int) string {
+	return fmt.Sprintf("m%d-%d-", n, i)
+}
+
+// Limits applied to tool output shown in the expandable panels.
+const (
+	maxOutputLines = 200
+	maxOutputBytes = 32 * 1024
+)
+
+// buildTemplateTool converts a parsed tool call into its panel representation.
+func buildTemplateTool(call parser.ToolCall, opts Options, seq *int) TemplateTool {
+	tt := TemplateTool{
+		Label:   parser.ToolDisplayName(call.Name),
+		Summary: call.Summary(),
+		Detail:  call.Detail(),
+		IsError: call.IsError,
+	}
+
+	if path, _, ok := call.FileEdits(); ok {
+		tt.Lang = languageForPath(path)
+		for _, hunk := range call.Diff() {
+			tt.Hunks = append(tt.Hunks, buildDiffLines(hunk, tt.Lang))
+		}
+		// The result of a successful edit only echoes the file back.
+		if call.HasResult && call.IsError {
+			tt.Output = truncateOutput(call.Result)
+			tt.OutputHTML = template.HTML(html.EscapeString(tt.Output))
+		}
+		return tt
+	}
+
+	if len(call.Subagent) > 0 {
+		tt.Subagent, _, _ = buildTemplateMessages(call.Subagent, opts, seq)
+		for i := range tt.Subagent {
+			if tt.Subagent[i].Role == "user" {
+				tt.Subagent[i].Speaker = "Prompt"
+			} else {
+				tt.Subagent[i].Speaker = "Subagent"
+			}
+		}
+		tt.Detail = subagentDetail(call)
+	}
+
+	if call.Name == "Bash" {
+		tt.Input = call.Params().Command
+		tt.InputHTML = highlight(tt.Input, "bash")
+	} else if len(call.Input) > 0 {
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, call.Input, "", "  "); err == nil {
+			tt.Input = pretty.String()
+			tt.InputHTML = highlight(tt.Input, "json")
+		} else {
+			tt.Input = string(call.Input)
+			tt.InputHTML = template.HTML(html.EscapeString(tt.Input))
+		}
+	}
+
+	if call.HasResult {
+		tt.Output = truncateOutput(call.Result)
+		if call.Name == "Read" && !call.IsError {
+			tt.Lang = languageForPath(call.Params().FilePath)
+			tt.OutputHTML = highlightNumbered(tt.Output, tt.Lang)
+		} else {
+			tt.OutputHTML = template.HTML(html.EscapeString(tt.Output))
+		}
+	}
+	return tt
+}
+
+// subagentDetail describes a Task call's subagent: "Explore · 14 messages".
+func subagentDetail(call parser.ToolCall) string {
+	n := 0
+	for _, m := range call.Subagent {
+		if m.Role != "tool_group" {
+			n++
+		}
+	}
+	detail := fmt.Sprintf("%d messages", n)
+	if n == 1 {
+		detail = "1 message"
+	}
+	if t := call.Params().SubagentType; t != "" {
+		detail = t + " · " + detail
+	}
+	return detail
+}
+
+// buildDiffLines converts a line diff into template rows, highlighting the
+// text as lang. The hunk is tokenised as a whole so that constructs spanning
+// lines are coloured correctly in most edits.
+func buildDiffLines(lines []diff.Line, lang string) []TemplateDiffLine {
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		texts[i] = l.Text
+	}
+	highlighted, ok := highlightLines(strings.Join(texts, "\n"), lang)
+	if !ok || len(highlighted) != len(lines) {
+		highlighted = nil
+	}
+
+	rows := make([]TemplateDiffLine, 0, len(lines))
+	for i, l := range lines {
+		row := TemplateDiffLine{Sign: string(l.Op), Text: l.Text}
+		if highlighted != nil {
+			row.HTML = highlighted[i]
+		} else {
+			row.HTML = template.HTML(html.EscapeString(l.Text))
+		}
+		switch l.Op {
+		case diff.Insert:
+			row.Class = "add"
+		case diff.Delete:
+			row.Class = "del"
+		default:
+			row.Class = "ctx"
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// buildFileChanges converts per-file edit totals into sidebar rows, showing
+// paths relative to cwd where possible.
+func buildFileChanges(changes []parser.FileChange, cwd string) []TemplateFileChange {
+	var rows []TemplateFileChange
+	for _, c := range changes {
+		path := c.Path
+		if cwd != "" {
+			if rel, ok := strings.CutPrefix(path, strings.TrimSuffix(cwd, "/")+"/"); ok {
+				path = rel
+			}
+		}
+		rows = append(rows, TemplateFileChange{Path: path, Added: c.Added, Removed: c.Removed})
+	}
+	return rows
+}
+
+// languagesByExt maps file extensions to the language names used in
+// code block classes.
+var languagesByExt = map[string]string{
+	".go":   "go",
+	".ts":   "typescript",
+	".tsx":  "tsx",
+	".js":   "javascript",
+	".jsx":  "jsx",
+	".mjs":  "javascript",
+	".py":   "python",
+	".rs":   "rust",
+	".sh":   "bash",
+	".bash": "bash",
+	".zsh":  "bash",
+	".sql":  "sql",
+	".json": "json",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".toml": "toml",
+	".md":   "markdown",
+	".html": "html",
+	".css":  "css",
+	".diff": "diff",
+}
+
+// languageForPath guesses a file's language from its extension.
+func languageForPath(path string) string {
+	return languagesByExt[strings.ToLower(filepath.Ext(path))]
+}
+
+// truncateOutput shortens long tool output to maxOutputLines lines and
+// maxOutputBytes bytes, noting how much was omitted.
+func truncateOutput(s string) string {
+	s = strings.TrimRight(s, "\n")
+	lines := strings.Split(s, "\n")
+	omitted := 0
+	if len(lines) > maxOutputLines {
+		omitted = len(lines) - maxOutputLines
+		lines = lines[:maxOutputLines]
+	}
+	out := strings.Join(lines, "\n")
+	truncated := false
+	if len(out) > maxOutputBytes {
+		cut := strings.LastIndex(out[:maxOutputBytes], "\n")
+		if cut <= 0 {
+			cut = maxOutputBytes
+		}
+		omitted += strings.Count(out[cut:], "\n")
+		out = strings.ToValidUTF8(out[:cut], "")
+		truncated = true
+	}
+	switch {
+	case omitted > 0:
+		out += fmt.Sprintf("\n… %d more lines", omitted)
+	case truncated:
+		out += "\n… output truncated"
+	}
+	return out
+}
+
+// buildUsage converts per-model token totals into sidebar rows.
+func buildUsage(usage []parser.ModelUsage) []TemplateUsage {
+	var rows []TemplateUsage
+	for _, u := range usage {
+		t := u.Tokens
+		rows = append(rows, TemplateUsage{
+			Model:  u.Model,
+			Tokens: FormatTokens(t.Total()) + " tokens",
+			Detail: fmt.Sprintf("%s in · %s out · %s cache read · %s cache write",
+				FormatTokens(t.Input), FormatTokens(t.Output),
+				FormatTokens(t.CacheRead), FormatTokens(t.CacheCreation)),
+			Cost: formatCost(u.Cost, u.Priced),
+		})
+	}
+	return rows
+}
+
+// FormatTokens abbreviates a token count: 950, 12.4k, 1.23M.
+func FormatTokens(n int) string {
+	if n < 1000 {
+		return fmt.Sprintf("%d", n)
+	}
+	// A count that rounds up to 1000k is shown in millions instead.
+	if k := fmt.Sprintf("%.1f", float64(n)/1000); n < 1_000_000 && k != "1000.0" {
+		return trimZeros(k) + "k"
+	}
+	return trimZeros(fmt.Sprintf("%.2f", float64(n)/1_000_000)) + "M"
+}
+
+// trimZeros removes the trailing zeros of a decimal, and its point if no
+// digits are left after it.
+func trimZeros(s string) string {
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// tokenBadge formats the usage shown next to a message, or returns "" if
+// the message has none.
+func tokenBadge(u *parser.Usage) string {
+	if u == nil {
+		return ""
+	}
+	in := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	return FormatTokens(in) + " in · " + FormatTokens(u.OutputTokens) + " out"
+}
+
+// formatCost formats an estimated USD cost, or "—" if it is unknown.
+func formatCost(cost float64, priced bool) string {
+	switch {
+	case !priced:
+		return "—"
+	case cost > 0 && cost < 0.01:
+		return "<$0.01"
+	default:
+		return fmt.Sprintf("$%.2f", cost)
 	}
-	return buf.Bytes(), nil
 }
 
 // formatTimestamp converts an ISO 8601 timestamp to a display format like "Jan 02, 3:04 PM".
@@ -0,0 +1,27 @@
+package render
+
+import "testing"
+
+func TestFormatTokens(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1k"},
+		{1050, "1.1k"},
+		{12_345, "12.3k"},
+		{999_940, "999.9k"},
+		{999_950, "1M"}, // rounds up to 1000k
+		{1_000_000, "1M"},
+		{1_500_000, "1.5M"},
+		{1_234_567, "1.23M"},
+		{10_000_000, "10M"},
+	}
+	for _, tt := range tests {
+		if got := FormatTokens(tt.n); got != tt.want {
+			t.Errorf("FormatTokens(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
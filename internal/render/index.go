@@ -0,0 +1,77 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+)
+
+// IndexSession is a row of the archive index written by GenerateIndex.
+type IndexSession struct {
+	Title          string `json:"title"`
+	Project        string `json:"project"`
+	DateRange      string `json:"date_range"` // pre-formatted: "Jan 02, 2006"
+	Start          string `json:"start"`      // ISO 8601 timestamp of the first entry
+	Model          string `json:"model"`
+	UserCount      int    `json:"user_messages"`
+	AssistantCount int    `json:"assistant_messages"`
+	URL            string `json:"url"` // the session's page, relative to the index
+}
+
+// IndexData holds all data passed to the index template.
+type IndexData struct {
+	Title     string
+	Sessions  []IndexSession
+	Projects  []string // distinct projects, sorted, for the project filter
+	Theme     string
+	ThemeCSS  template.CSS
+	CustomCSS template.CSS
+}
+
+// IndexTitle is the heading of the archive index page.
+const IndexTitle = "Claude Code Sessions"
+
+// GenerateIndex renders the index page of a batch export: a searchable table
+// linking to each session's page. Only Theme and Template are used from opts;
+// a user template directory contributes its CSS, not its markup.
+func GenerateIndex(sessions []IndexSession, opts Options) ([]byte, error) {
+	theme := opts.Theme
+	if theme == "" {
+		theme = ThemeAuto
+	}
+	themeStyles, err := themeCSS(theme)
+	if err != nil {
+		return nil, err
+	}
+	customCSS, err := loadCustomCSS(opts.Template)
+	if err != nil {
+		return nil, fmt.Errorf("load template CSS: %w", err)
+	}
+	tmpl, err := template.ParseFS(tmplFS, "template/index.html")
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+
+	data := IndexData{
+		Title:     IndexTitle,
+		Sessions:  sessions,
+		Theme:     theme,
+		ThemeCSS:  themeStyles,
+		CustomCSS: customCSS,
+	}
+	seen := make(map[string]bool)
+	for _, s := range sessions {
+		if !seen[s.Project] {
+			seen[s.Project] = true
+			data.Projects = append(data.Projects, s.Project)
+		}
+	}
+	sort.Strings(data.Projects)
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("execute template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
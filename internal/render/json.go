@@ -0,0 +1,225 @@
+package render
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"github.com/HabibPro1999/shiplog/internal/parser"
+)
+
+// JSONSchemaVersion is the version of the document written by GenerateJSON.
+// It is bumped whenever a field is removed or changes meaning; new optional
+// fields may be added without a bump.
+const JSONSchemaVersion = 1
+
+// JSONSchemaURL identifies the JSON Schema describing the exported document.
+const JSONSchemaURL = "https://raw.githubusercontent.com/HabibPro1999/shiplog/main/internal/render/schema/transcript.v1.schema.json"
+
+// JSONSchema is the JSON Schema of the exported document.
+//
+//go:embed schema/transcript.v1.schema.json
+var JSONSchema []byte
+
+// JSONDocument is the top-level object written by GenerateJSON.
+type JSONDocument struct {
+	Schema        string        `json:"$schema"`
+	SchemaVersion int           `json:"schema_version"`
+	Session       JSONSession   `json:"session"`
+	Messages      []JSONMessage `json:"messages"`
+}
+
+// JSONSession holds the session's metadata.
+type JSONSession struct {
+	ID               string         `json:"id"`
+	Title            string         `json:"title"`
+	Project          string         `json:"project"`
+	CWD              string         `json:"cwd,omitempty"`
+	Model            string         `json:"model,omitempty"`
+	StartedAt        string         `json:"started_at,omitempty"`
+	EndedAt          string         `json:"ended_at,omitempty"`
+	UserMessages     int            `json:"user_messages"`
+	AssistantReplies int            `json:"assistant_messages"`
+	Usage            []JSONUsage    `json:"usage"`
+	FilesChanged     []JSONFileDiff `json:"files_changed"`
+}
+
+// JSONUsage is the token usage and estimated cost of one model.
+type JSONUsage struct {
+	Model         string   `json:"model"`
+	Input         int      `json:"input_tokens"`
+	Output        int      `json:"output_tokens"`
+	CacheCreation int      `json:"cache_creation_input_tokens"`
+	CacheRead     int      `json:"cache_read_input_tokens"`
+	Cost          *float64 `json:"estimated_cost_usd"` // null when the model has no known price
+}
+
+// JSONFileDiff summarises the edits made to one file.
+type JSONFileDiff struct {
+	Path    string `json:"path"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+// JSONMessage is one message of the conversation. Fields not used by a role
+// are omitted.
+type JSONMessage struct {
+	Role      string          `json:"role"` // "user", "assistant", "tool_group" or "fork"
+	UUID      string          `json:"uuid,omitempty"`
+	Timestamp string          `json:"timestamp,omitempty"`
+	Texts     []string        `json:"texts,omitempty"`
+	Thinking  []string        `json:"thinking,omitempty"`
+	Images    []JSONImage     `json:"images,omitempty"`
+	Usage     *JSONTokens     `json:"usage,omitempty"`
+	ToolCalls []JSONToolCall  `json:"tool_calls,omitempty"`
+	Branches  [][]JSONMessage `json:"branches,omitempty"`
+}
+
+// JSONImage is an image attached to a user message.
+type JSONImage struct {
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"` // base64
+}
+
+// JSONTokens is the token usage of the API responses behind a message.
+type JSONTokens struct {
+	Input         int `json:"input_tokens"`
+	Output        int `json:"output_tokens"`
+	CacheCreation int `json:"cache_creation_input_tokens"`
+	CacheRead     int `json:"cache_read_input_tokens"`
+}
+
+// JSONToolCall is a tool call paired with its result.
+type JSONToolCall struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	DisplayName string          `json:"display_name"`
+	Summary     string          `json:"summary,omitempty"`
+	Detail      string          `json:"detail,omitempty"`
+	Input       json.RawMessage `json:"input,omitempty"`
+	Result      *string         `json:"result"` // null when no result was recorded
+	IsError     bool            `json:"is_error"`
+	AgentID     string          `json:"agent_id,omitempty"`
+	Subagent    []JSONMessage   `json:"subagent,omitempty"`
+}
+
+// GenerateJSON renders messages and metadata as a versioned JSON document
+// described by JSONSchema. Tool results are not truncated.
+func GenerateJSON(messages []parser.Message, meta parser.SessionMeta, project string, opts Options) ([]byte, error) {
+	userCount, assistantCount := CountMessages(messages)
+	doc := JSONDocument{
+		Schema:        JSONSchemaURL,
+		SchemaVersion: JSONSchemaVersion,
+		Session: JSONSession{
+			ID:               meta.SessionID,
+			Title:            meta.Title,
+			Project:          project,
+			CWD:              meta.CWD,
+			Model:            meta.ModelID,
+			StartedAt:        meta.Start,
+			EndedAt:          meta.End,
+			UserMessages:     userCount,
+			AssistantReplies: assistantCount,
+			Usage:            []JSONUsage{},
+			FilesChanged:     []JSONFileDiff{},
+		},
+		Messages: buildJSONMessages(messages, opts),
+	}
+	for _, u := range meta.Usage {
+		ju := JSONUsage{
+			Model:         u.Model,
+			Input:         u.Tokens.Input,
+			Output:        u.Tokens.Output,
+			CacheCreation: u.Tokens.CacheCreation,
+			CacheRead:     u.Tokens.CacheRead,
+		}
+		if u.Priced {
+			cost := u.Cost
+			ju.Cost = &cost
+		}
+		doc.Session.Usage = append(doc.Session.Usage, ju)
+	}
+	for _, c := range buildFileChanges(parser.FileChanges(messages), meta.CWD) {
+		doc.Session.FilesChanged = append(doc.Session.FilesChanged, JSONFileDiff{Path: c.Path, Added: c.Added, Removed: c.Removed})
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode JSON: %w", err)
+	}
+	return append(out, '\n'), nil
+}
+
+// buildJSONMessages converts parsed messages into their JSON form, dropping
+// the same empty assistant messages as the HTML page.
+func buildJSONMessages(messages []parser.Message, opts Options) []JSONMessage {
+	out := []JSONMessage{}
+	for _, msg := range messages {
+		jm := JSONMessage{Role: msg.Role, UUID: msg.UUID, Timestamp: msg.Timestamp}
+		switch msg.Role {
+		case "user":
+			jm.Texts = msg.Texts
+			for _, img := range msg.Images {
+				jm.Images = append(jm.Images, JSONImage{MediaType: img.MediaType, Data: img.Data})
+			}
+		case "assistant":
+			jm.Texts = msg.Texts
+			if opts.Thinking == ThinkingCollapsed || opts.Thinking == ThinkingShow {
+				jm.Thinking = msg.Thinking
+			}
+			if len(jm.Texts) == 0 && len(jm.Thinking) == 0 {
+				continue
+			}
+			jm.Usage = jsonTokens(msg.Usage)
+		case "tool_group":
+			for _, call := range msg.Tools {
+				jm.ToolCalls = append(jm.ToolCalls, buildJSONToolCall(call, opts))
+			}
+			jm.Usage = jsonTokens(msg.Usage)
+		case "fork":
+			for _, branch := range msg.Branches {
+				jm.Branches = append(jm.Branches, buildJSONMessages(branch, opts))
+			}
+		}
+		out = append(out, jm)
+	}
+	return out
+}
+
+// jsonTokens converts a message's usage into its JSON form, or returns nil
+// if it has none.
+func jsonTokens(u *parser.Usage) *JSONTokens {
+	if u == nil {
+		return nil
+	}
+	return &JSONTokens{
+		Input:         u.InputTokens,
+		Output:        u.OutputTokens,
+		CacheCreation: u.CacheCreationInputTokens,
+		CacheRead:     u.CacheReadInputTokens,
+	}
+}
+
+// buildJSONToolCall converts a tool call into its JSON form.
+func buildJSONToolCall(call parser.ToolCall, opts Options) JSONToolCall {
+	jc := JSONToolCall{
+		ID:          call.ID,
+		Name:        call.Name,
+		DisplayName: parser.ToolDisplayName(call.Name),
+		Summary:     call.Summary(),
+		Detail:      call.Detail(),
+		IsError:     call.IsError,
+		AgentID:     call.AgentID,
+	}
+	if json.Valid(call.Input) {
+		jc.Input = call.Input
+	}
+	if call.HasResult {
+		result := call.Result
+		jc.Result = &result
+	}
+	if len(call.Subagent) > 0 {
+		jc.Subagent = buildJSONMessages(call.Subagent, opts)
+	}
+	return jc
+}
@@ -0,0 +1,277 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path"
+	"strings"
+
+	"github.com/HabibPro1999/shiplog/internal/parser"
+)
+
+// Asset is a file referenced by a generated document, such as an image
+// written next to a Markdown export.
+type Asset struct {
+	Name string // slash-separated path relative to the document
+	Data []byte
+}
+
+// imageExts maps image media types to file extensions.
+var imageExts = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// GenerateMarkdown renders messages and metadata as a GitHub-flavored
+// Markdown transcript. Images are embedded as data URIs, or returned as
+// assets under opts.AssetDir when it is set.
+func GenerateMarkdown(messages []parser.Message, meta parser.SessionMeta, project string, opts Options) ([]byte, []Asset, error) {
+	w := &markdownWriter{opts: opts}
+	userCount, assistantCount := CountMessages(messages)
+
+	w.printf("# %s\n\n", meta.Title)
+
+	w.printf("| | |\n| --- | --- |\n")
+	w.row("Project", project)
+	w.row("Date", meta.DateRange)
+	w.row("Model", meta.Model)
+	w.row("Messages", fmt.Sprintf("%d user · %d assistant", userCount, assistantCount))
+	if len(meta.Usage) > 0 {
+		cost, priced := meta.TotalCost()
+		w.row("Tokens", FormatTokens(meta.TotalTokens().Total()))
+		w.row("Estimated cost", formatCost(cost, priced))
+	}
+	w.printf("\n")
+
+	if changes := buildFileChanges(parser.FileChanges(messages), meta.CWD); len(changes) > 0 {
+		w.printf("<details>\n<summary>%s</summary>\n\n", parser.Plural(len(changes), "file changed", "files changed"))
+		for _, c := range changes {
+			w.printf("- %s +%d −%d\n", codeSpan(c.Path), c.Added, c.Removed)
+		}
+		w.printf("\n</details>\n\n")
+	}
+
+	w.printf("---\n\n")
+	w.messages(messages, 0, "You", "Claude")
+
+	return []byte(strings.TrimRight(w.buf.String(), "\n") + "\n"), w.assets, nil
+}
+
+// markdownWriter accumulates a Markdown document and the assets it references.
+type markdownWriter struct {
+	buf    strings.Builder
+	opts   Options
+	assets []Asset
+}
+
+func (w *markdownWriter) printf(format string, args ...any) {
+	fmt.Fprintf(&w.buf, format, args...)
+}
+
+// row writes a metadata table row, skipping empty values.
+func (w *markdownWriter) row(label, value string) {
+	if value == "" {
+		return
+	}
+	w.printf("| **%s** | %s |\n", label, strings.ReplaceAll(value, "|", `\|`))
+}
+
+// messages writes a message list, labelling turns with the given speakers.
+// Top-level turns get headings; nested ones (subagents and abandoned
+// branches) get bold labels, since headings inside <details> clutter the
+// document outline.
+func (w *markdownWriter) messages(messages []parser.Message, depth int, user, assistant string) {
+	for _, msg := range messages {
+		switch msg.Role {
+		case "user":
+			w.speaker(user, msg.Timestamp, depth)
+			// Prompts are plain text, as in the HTML page: a fence keeps a
+			// leading "#" or raw HTML from changing the document.
+			for _, t := range msg.Texts {
+				w.fenced(strings.TrimSpace(t), "")
+			}
+			for _, img := range msg.Images {
+				w.image(img)
+			}
+		case "assistant":
+			var thinking []string
+			if w.opts.Thinking == ThinkingCollapsed || w.opts.Thinking == ThinkingShow {
+				thinking = msg.Thinking
+			}
+			if len(msg.Texts) == 0 && len(thinking) == 0 {
+				continue
+			}
+			w.speaker(assistant, msg.Timestamp, depth)
+			for _, t := range thinking {
+				w.thinking(t)
+			}
+			for _, t := range msg.Texts {
+				w.printf("%s\n\n", strings.TrimSpace(t))
+			}
+		case "tool_group":
+			for _, call := range msg.Tools {
+				w.tool(call, depth)
+			}
+		case "fork":
+			label := "1 abandoned branch"
+			if len(msg.Branches) > 1 {
+				label = fmt.Sprintf("%d abandoned branches", len(msg.Branches))
+			}
+			w.printf("<details>\n<summary>%s</summary>\n\n", label)
+			for i, branch := range msg.Branches {
+				w.printf("**Branch %d**\n\n", i+1)
+				w.messages(branch, depth+1, user, assistant)
+			}
+			w.printf("</details>\n\n")
+		}
+	}
+}
+
+// speaker writes the label that starts a turn.
+func (w *markdownWriter) speaker(name, timestamp string, depth int) {
+	ts := formatTimestamp(timestamp)
+	switch {
+	case depth == 0 && ts != "":
+		w.printf("## %s · %s\n\n", name, ts)
+	case depth == 0:
+		w.printf("## %s\n\n", name)
+	case ts != "":
+		w.printf("**%s** · %s\n\n", name, ts)
+	default:
+		w.printf("**%s**\n\n", name)
+	}
+}
+
+// thinking writes a reasoning block, collapsed unless opts.Thinking is
+// ThinkingShow.
+func (w *markdownWriter) thinking(text string) {
+	text = strings.TrimSpace(text)
+	if w.opts.Thinking == ThinkingShow {
+		w.printf("> *Thinking*\n>\n%s\n\n", quote(text))
+		return
+	}
+	w.printf("<details>\n<summary>Thinking</summary>\n\n%s\n\n</details>\n\n", text)
+}
+
+// tool writes a tool call as a collapsed <details> block.
+func (w *markdownWriter) tool(call parser.ToolCall, depth int) {
+	summary := "<b>" + html.EscapeString(parser.ToolDisplayName(call.Name)) + "</b>"
+	if s := call.Summary(); s != "" {
+		summary += " <code>" + html.EscapeString(s) + "</code>"
+	}
+	detail := call.Detail()
+	if len(call.Subagent) > 0 {
+		detail = subagentDetail(call)
+	}
+	if detail != "" {
+		summary += " · " + html.EscapeString(detail)
+	}
+	if call.IsError {
+		summary += " · error"
+	}
+	w.printf("<details>\n<summary>%s</summary>\n\n", summary)
+
+	if len(call.Subagent) > 0 {
+		// The subagent's final reply is also the call's result.
+		w.messages(call.Subagent, depth+1, "Prompt", "Subagent")
+		w.printf("</details>\n\n")
+		return
+	}
+	if _, _, ok := call.FileEdits(); ok {
+		var lines []string
+		for _, hunk := range call.Diff() {
+			if len(lines) > 0 {
+				lines = append(lines, "@@")
+			}
+			for _, l := range hunk {
+				lines = append(lines, string(l.Op)+l.Text)
+			}
+		}
+		if len(lines) > 0 {
+			w.fenced(strings.Join(lines, "\n"), "diff")
+		}
+		// The result of a successful edit only echoes the file back.
+		if call.HasResult && call.IsError {
+			w.fenced(truncateOutput(call.Result), "")
+		}
+		w.printf("</details>\n\n")
+		return
+	}
+	if call.Name == "Bash" {
+		if cmd := call.Params().Command; cmd != "" {
+			w.fenced(cmd, "bash")
+		}
+	} else if len(call.Input) > 0 {
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, call.Input, "", "  "); err == nil {
+			w.fenced(pretty.String(), "json")
+		} else {
+			w.fenced(string(call.Input), "")
+		}
+	}
+	if call.HasResult {
+		if out := truncateOutput(call.Result); out != "" {
+			w.fenced(out, "")
+		}
+	}
+	w.printf("</details>\n\n")
+}
+
+// fenced writes text as a fenced code block whose fence is longer than any
+// backtick run in the text.
+func (w *markdownWriter) fenced(text, lang string) {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	w.printf("%s%s\n%s\n%s\n\n", fence, lang, strings.TrimRight(text, "\n"), fence)
+}
+
+// codeSpan returns s as inline code, delimited by a backtick run longer
+// than any in s.
+func codeSpan(s string) string {
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	// A space keeps a backtick at either end from joining the fence.
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+// image writes an image as a data URI or, with opts.AssetDir, as a link to
+// a file returned among the document's assets.
+func (w *markdownWriter) image(img parser.Image) {
+	if w.opts.AssetDir == "" {
+		w.printf("![image](data:%s;base64,%s)\n\n", img.MediaType, img.Data)
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(img.Data)
+	if err != nil {
+		w.printf("*[image could not be decoded]*\n\n")
+		return
+	}
+	ext, ok := imageExts[img.MediaType]
+	if !ok {
+		ext = ".bin"
+	}
+	name := path.Join(w.opts.AssetDir, fmt.Sprintf("image-%d%s", len(w.assets)+1, ext))
+	w.assets = append(w.assets, Asset{Name: name, Data: data})
+	w.printf("![image](%s)\n\n", strings.ReplaceAll(name, " ", "%20"))
+}
+
+// quote prefixes each line of text with "> ".
+func quote(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight("> "+l, " ")
+	}
+	return strings.Join(lines, "\n")
+}
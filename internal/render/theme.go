@@ -0,0 +1,216 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Built-in themes for Options.Theme. ThemeAuto follows the reader's
+// prefers-color-scheme setting and adds a light/dark toggle to the page.
+const (
+	ThemeAuto         = "auto"
+	ThemeLight        = "light"
+	ThemeDark         = "dark"
+	ThemeHighContrast = "high-contrast"
+	ThemePrint        = "print"
+)
+
+// themeCodeStyles maps each theme to the chroma style of its code blocks.
+var themeCodeStyles = map[string]string{
+	ThemeAuto:         "gruvbox",
+	ThemeLight:        "gruvbox",
+	ThemeDark:         "gruvbox",
+	ThemeHighContrast: "hrdark",
+	ThemePrint:        "github",
+}
+
+// Themes returns the names of the built-in themes.
+func Themes() []string {
+	return []string{ThemeAuto, ThemeLight, ThemeDark, ThemeHighContrast, ThemePrint}
+}
+
+// themeCSS returns the stylesheet of a theme: the light palette, overridden
+// by the theme's own variables and rules. The auto theme applies the dark
+// palette when the system prefers it, unless the page's toggle chose light,
+// and whenever the toggle chose dark.
+func themeCSS(name string) (template.CSS, error) {
+	if name == "" {
+		name = ThemeAuto
+	}
+	if _, ok := themeCodeStyles[name]; !ok {
+		return "", fmt.Errorf("unknown theme %q (want %s)", name, strings.Join(Themes(), ", "))
+	}
+	css, err := tmplFS.ReadFile("template/themes/" + ThemeLight + ".css")
+	if err != nil {
+		return "", err
+	}
+	switch name {
+	case ThemeLight:
+	case ThemeAuto:
+		dark, err := tmplFS.ReadFile("template/themes/" + ThemeDark + ".css")
+		if err != nil {
+			return "", err
+		}
+		system := strings.Replace(string(dark), ":root {", `:root:not([data-theme="light"]) {`, 1)
+		chosen := strings.Replace(string(dark), ":root {", `:root[data-theme="dark"] {`, 1)
+		css = fmt.Appendf(css, "\n@media (prefers-color-scheme: dark) {\n%s}\n%s", system, chosen)
+	default:
+		extra, err := tmplFS.ReadFile("template/themes/" + name + ".css")
+		if err != nil {
+			return "", err
+		}
+		css = append(append(css, '\n'), extra...)
+	}
+	return template.CSS(css), nil
+}
+
+// codeCSS returns the token colours of a theme's code blocks.
+func codeCSS(name string) template.CSS {
+	style, ok := themeCodeStyles[name]
+	if !ok {
+		style = themeCodeStyles[ThemeAuto]
+	}
+	return template.CSS(tokenCSS(styles.Get(style)))
+}
+
+// loadTemplate parses the page template: the built-in one, or the chat.html
+// of a user template directory along with any other .html files there,
+// which may define partials. A directory without chat.html keeps the
+// built-in template, so it can supply CSS only.
+func loadTemplate(dir string) (*template.Template, error) {
+	funcMap := template.FuncMap{
+		"safeHTML": func(s template.HTML) template.HTML { return s },
+		"inc":      func(i int) int { return i + 1 },
+	}
+	tmpl := template.New("chat.html").Funcs(funcMap)
+
+	if dir != "" {
+		if _, err := os.Stat(filepath.Join(dir, "chat.html")); err == nil {
+			pages, err := filepath.Glob(filepath.Join(dir, "*.html"))
+			if err != nil {
+				return nil, err
+			}
+			t, err := tmpl.ParseFiles(pages...)
+			if err != nil {
+				return nil, fmt.Errorf("parse template: %w", err)
+			}
+			return t, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	t, err := tmpl.ParseFS(tmplFS, "template/chat.html")
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	return t, nil
+}
+
+// loadCustomCSS concatenates the .css files of a user template directory in
+// name order.
+func loadCustomCSS(dir string) (template.CSS, error) {
+	if dir == "" {
+		return "", nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.css"))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+	var b strings.Builder
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "/* %s */\n%s\n", filepath.Base(f), data)
+	}
+	return template.CSS(b.String()), nil
+}
+
+// ValidateTemplate checks that a user template directory exists and that its
+// template parses and only uses documented TemplateData fields. The template
+// is executed against sample data covering every message role and tool
+// panel, so that mistakes surface before a real session happens to reach
+// the branch that contains them.
+func ValidateTemplate(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	tmpl, err := loadTemplate(dir)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(io.Discard, sampleData()); err != nil {
+		return fmt.Errorf("execute template: %w", err)
+	}
+	return nil
+}
+
+// sampleData returns TemplateData with every optional part filled in.
+func sampleData() TemplateData {
+	tool := TemplateTool{
+		Label:      "Edit file",
+		Summary:    "main.go",
+		Detail:     "+1 −1",
+		Input:      "{}",
+		Output:     "ok",
+		IsError:    true,
+		Lang:       "go",
+		Hunks:      [][]TemplateDiffLine{{{Class: "del", Sign: "-", Text: "a", HTML: "a"}, {Class: "add", Sign: "+", Text: "b", HTML: "b"}}},
+		InputHTML:  "{}",
+		OutputHTML: "ok",
+	}
+	user := TemplateMessage{
+		Role:      "user",
+		Anchor:    "msg-1",
+		Speaker:   "You",
+		Texts:     []template.HTML{"Hello"},
+		Images:    nil,
+		Timestamp: "Jan 02, 3:04 PM",
+	}
+	assistant := TemplateMessage{
+		Role:         "assistant",
+		Anchor:       "msg-2",
+		Speaker:      "Claude",
+		Texts:        []template.HTML{"<p>Hi</p>"},
+		Thinking:     []string{"reasoning"},
+		ThinkingOpen: true,
+		Tokens:       "1k in · 10 out",
+		Timestamp:    "Jan 02, 3:05 PM",
+	}
+	subagent := tool
+	subagent.Subagent = []TemplateMessage{user, assistant}
+	group := TemplateMessage{Role: "tool_group", ToolLabel: "2 tool actions performed", Tools: []TemplateTool{tool, subagent}}
+	fork := TemplateMessage{Role: "fork", ForkLabel: "1 abandoned branch", Branches: [][]TemplateMessage{{user, assistant}}}
+
+	return TemplateData{
+		Title:          "Sample session",
+		IndexURL:       "../index.html",
+		Project:        "example/project",
+		DateRange:      "Jan 02, 2026",
+		Model:          "Claude Sonnet",
+		UserCount:      1,
+		AssistantCount: 1,
+		FilesChanged:   []TemplateFileChange{{Path: "main.go", Added: 1, Removed: 1}},
+		Usage:          []TemplateUsage{{Model: "claude-sonnet-4-5", Tokens: "1k tokens", Detail: "1k in", Cost: "$0.01"}},
+		TotalTokens:    "1k tokens",
+		TotalCost:      "$0.01",
+		Messages:       []TemplateMessage{user, assistant, group, fork},
+		Outline:        []TemplateOutlineItem{{Turn: 1, Anchor: "msg-1", Label: "Hello", Timestamp: "Jan 02, 3:04 PM"}},
+		Theme:          ThemeAuto,
+	}
+}
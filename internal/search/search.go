@@ -0,0 +1,260 @@
+// Package search finds sessions by the text of their conversations.
+package search
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/HabibPro1999/shiplog/internal/parser"
+	"github.com/HabibPro1999/shiplog/internal/session"
+)
+
+// Query is a parsed search: terms are matched case-insensitively as
+// substrings, and a session matches if it contains any of them.
+type Query struct {
+	Terms []string
+	Tools bool // also search tool inputs, tool results and subagent conversations
+
+	patterns []*regexp.Regexp // one per term
+	pattern  *regexp.Regexp   // any term, for highlighting
+}
+
+// NewQuery builds a query from command-line arguments. An argument with
+// spaces, such as a quoted "redirect uri", is matched as one phrase whose
+// words may be separated by any whitespace.
+func NewQuery(args []string, tools bool) Query {
+	q := Query{Tools: tools}
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		t := strings.ToLower(strings.Join(strings.Fields(arg), " "))
+		if t != "" && !seen[t] {
+			seen[t] = true
+			q.Terms = append(q.Terms, t)
+		}
+	}
+	if len(q.Terms) == 0 {
+		return q
+	}
+	alts := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		words := strings.Fields(t)
+		for j, w := range words {
+			words[j] = regexp.QuoteMeta(w)
+		}
+		alts[i] = strings.Join(words, `\s+`)
+		q.patterns = append(q.patterns, regexp.MustCompile("(?i)"+alts[i]))
+	}
+	// Longer terms first, so that "redirect" is highlighted whole rather
+	// than as "red".
+	sort.Slice(alts, func(i, j int) bool { return len(alts[i]) > len(alts[j]) })
+	q.pattern = regexp.MustCompile("(?i)" + strings.Join(alts, "|"))
+	return q
+}
+
+// Empty reports whether the query has no terms.
+func (q Query) Empty() bool {
+	return len(q.Terms) == 0
+}
+
+// Highlight returns s with every occurrence of a term wrapped by mark.
+func (q Query) Highlight(s string, mark func(string) string) string {
+	if q.pattern == nil {
+		return s
+	}
+	return q.pattern.ReplaceAllStringFunc(s, mark)
+}
+
+// Hit is a session that contains at least one term.
+type Hit struct {
+	Session   session.SessionInfo
+	Score     float64 // set by Rank
+	Count     int     // occurrences of all terms
+	Timestamp string  // of the message the snippet comes from
+	Role      string  // "user", "assistant" or the tool's name
+	Snippet   string  // one line of text around the best match
+
+	counts []int // occurrences per term
+	best   int   // distinct terms in the snippet's message
+}
+
+// snippetWidth is the length of a snippet in characters.
+const snippetWidth = 160
+
+// Session parses a session and returns its hit, or nil if it contains none
+// of the terms. Only the latest branch of the conversation is searched.
+func Session(info session.SessionInfo, q Query) (*Hit, error) {
+	if q.Empty() {
+		return nil, nil
+	}
+	t, err := parser.ParseSession(info.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := parser.BuildConversation(t.Entries, parser.BranchLatest)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Hit{Session: info, counts: make([]int, len(q.Terms))}
+	bestCount := 0
+	lastTime := ""
+	each(messages, q.Tools, func(msg parser.Message, role, text string) {
+		// Tool groups have no timestamp of their own.
+		if msg.Timestamp != "" {
+			lastTime = msg.Timestamp
+		}
+		// Each term is counted on its own, so that "red" is found inside
+		// "redirect" even when both are searched for.
+		found, count, first := 0, 0, len(text)
+		for i, re := range q.patterns {
+			locs := re.FindAllStringIndex(text, -1)
+			if locs == nil {
+				continue
+			}
+			h.counts[i] += len(locs)
+			found++
+			count += len(locs)
+			first = min(first, locs[0][0])
+		}
+		if found == 0 {
+			return
+		}
+		h.Count += count
+		// The snippet comes from the text with the most distinct terms,
+		// then the most occurrences.
+		if found > h.best || found == h.best && count > bestCount {
+			h.best, bestCount = found, count
+			h.Timestamp, h.Role = lastTime, role
+			h.Snippet = snippet(text, first)
+		}
+	})
+	if h.Count == 0 {
+		return nil, nil
+	}
+	return h, nil
+}
+
+// each calls f with every searchable text of messages and the role it is
+// shown under.
+func each(messages []parser.Message, tools bool, f func(msg parser.Message, role, text string)) {
+	for _, msg := range messages {
+		switch msg.Role {
+		case "user", "assistant":
+			for _, text := range msg.Texts {
+				f(msg, msg.Role, text)
+			}
+		case "tool_group":
+			if !tools {
+				continue
+			}
+			for _, call := range msg.Tools {
+				for _, s := range jsonStrings(call.Input) {
+					f(msg, call.Name, s)
+				}
+				f(msg, call.Name, call.Result)
+				each(call.Subagent, tools, f)
+			}
+		}
+	}
+}
+
+// jsonStrings returns the string values of a tool input.
+func jsonStrings(raw json.RawMessage) []string {
+	var v any
+	if json.Unmarshal(raw, &v) != nil {
+		return nil
+	}
+	var out []string
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case string:
+			out = append(out, v)
+		case []any:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]any:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(v[k])
+			}
+		}
+	}
+	walk(v)
+	return out
+}
+
+// snippet returns about snippetWidth characters of text around the byte
+// offset at, on one line, with an ellipsis where text was cut.
+func snippet(text string, at int) string {
+	start := at
+	for n := 0; start > 0 && n < snippetWidth/3; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	// Start at a word.
+	if i := strings.IndexAny(text[start:at], " \t\n"); start > 0 && i >= 0 {
+		start += i + 1
+	}
+	end := start
+	for n := 0; end < len(text) && n < snippetWidth; n++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+	s := strings.Join(strings.Fields(text[start:end]), " ")
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(text) {
+		s += "…"
+	}
+	return s
+}
+
+// Rank scores hits and sorts them best first, the most recent session first
+// among equals. A term counts for more the fewer of the total sessions
+// searched contain it, and a session scores higher when one message holds
+// several terms.
+func Rank(hits []*Hit, total int) {
+	if len(hits) == 0 {
+		return
+	}
+	df := make([]int, len(hits[0].counts))
+	for _, h := range hits {
+		for i, n := range h.counts {
+			if n > 0 {
+				df[i]++
+			}
+		}
+	}
+	idf := make([]float64, len(df))
+	for i, n := range df {
+		if n > 0 {
+			idf[i] = math.Log(1 + float64(total)/float64(n))
+		}
+	}
+	for _, h := range hits {
+		h.Score = 0
+		for i, n := range h.counts {
+			if n > 0 {
+				h.Score += idf[i] * (1 + math.Log(float64(n)))
+			}
+		}
+		h.Score *= float64(h.best)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Session.Timestamp > hits[j].Session.Timestamp
+	})
+}
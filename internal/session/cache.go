@@ -0,0 +1,152 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheVersion is bumped when the cached fields change; caches of other
+// versions are discarded and rebuilt.
+const cacheVersion = 3
+
+// Cache is a persistent index of session headers, keyed by transcript path.
+// An entry is reused while the file's size and modification time are
+// unchanged, so listing sessions only reads new or modified transcripts. A
+// Cache is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	path    string
+	files   map[string]cachedFile
+	changed bool
+}
+
+// cachedFile is the cached header of one transcript.
+type cachedFile struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Header  header    `json:"header"`
+}
+
+type cacheFile struct {
+	Version int                   `json:"version"`
+	Files   map[string]cachedFile `json:"files"`
+}
+
+// DefaultCachePath returns the location of the session index,
+// e.g. ~/.cache/shiplog/sessions.json.
+func DefaultCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "shiplog", "sessions.json")
+}
+
+// LoadCache reads the session index at path. A missing, unreadable or
+// outdated index yields an empty cache that Save will replace. An empty path
+// gives a cache that is never saved.
+func LoadCache(path string) *Cache {
+	c := NewCache(path)
+	if path == "" {
+		return c
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	var saved cacheFile
+	if json.Unmarshal(data, &saved) == nil && saved.Version == cacheVersion && saved.Files != nil {
+		c.files = saved.Files
+		c.changed = false
+	}
+	return c
+}
+
+// NewCache returns an empty cache that Save writes to path, for rebuilding
+// the index from scratch.
+func NewCache(path string) *Cache {
+	return &Cache{path: path, files: make(map[string]cachedFile), changed: true}
+}
+
+// lookup returns the cached header of a transcript if the file is unchanged.
+func (c *Cache) lookup(path string, info os.FileInfo) (header, bool) {
+	if c == nil {
+		return header{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.files[path]
+	if !ok || f.Size != info.Size() || !f.ModTime.Equal(info.ModTime()) {
+		return header{}, false
+	}
+	return f.Header, true
+}
+
+// store records the header of a transcript.
+func (c *Cache) store(path string, info os.FileInfo, h header) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[path] = cachedFile{Size: info.Size(), ModTime: info.ModTime(), Header: h}
+	c.changed = true
+}
+
+// prune drops the entries of a scanned project directory whose transcripts
+// were not seen.
+func (c *Cache) prune(projDir string, seen map[string]bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path := range c.files {
+		if filepath.Dir(path) == projDir && !seen[path] {
+			delete(c.files, path)
+			c.changed = true
+		}
+	}
+}
+
+// Save writes the index if it changed since it was loaded.
+func (c *Cache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.path == "" || !c.changed {
+		return nil
+	}
+	data, err := json.Marshal(cacheFile{Version: cacheVersion, Files: c.files})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	// Write then rename, so that a concurrent shiplog never reads half a file.
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".sessions-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	c.changed = false
+	return nil
+}
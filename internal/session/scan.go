@@ -2,20 +2,38 @@ package session
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
+
+	"github.com/HabibPro1999/shiplog/internal/parser"
 )
 
 // PathToProjectDir converts a filesystem path to Claude's project dir name.
-// Claude replaces both / and _ with - in directory names.
-// e.g. /Users/mohamed/projects/value_slim_demo -> -Users-mohamed-projects-value-slim-demo
+// Claude replaces every character but ASCII letters and digits, such as /,
+// . and _, with - in directory names.
+// e.g. /Users/first.last/projects/value_slim_demo -> -Users-first-last-projects-value-slim-demo
 func PathToProjectDir(path string) string {
-	s := strings.ReplaceAll(path, "/", "-")
-	s = strings.ReplaceAll(s, "_", "-")
-	return s
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, path)
+}
+
+// ProjectName converts a project dir name to the readable project shown in
+// listings: each - becomes /, without the leading one.
+// e.g. -Users-first-last-projects-demo -> Users/first/last/projects/demo
+func ProjectName(projectDir string) string {
+	return strings.TrimLeft(strings.ReplaceAll(projectDir, "-", "/"), "/")
 }
 
 // ProjectDirsForCWD returns project dir names that match the current working directory.
@@ -45,10 +63,35 @@ func ProjectDirsForCWD(claudeDir string) []string {
 	return matches
 }
 
+// FileError is a transcript or project directory that could not be scanned.
+type FileError struct {
+	Path    string
+	Err     error
+	Partial bool // reading stopped at Err, and the session is listed with what came before
+}
+
+func (e FileError) Error() string {
+	// Errors from the os package already name the file.
+	var pathErr *fs.PathError
+	if errors.As(e.Err, &pathErr) && pathErr.Path == e.Path {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e FileError) Unwrap() error { return e.Err }
+
 // FindSessions scans project dirs and returns a list of SessionInfo.
-// If projectFilter is non-nil, only those dirs are scanned. Otherwise all dirs are scanned.
-// Results are sorted by timestamp descending (most recent first).
-func FindSessions(claudeDir string, projectFilter []string) ([]SessionInfo, error) {
+// If projectFilter is non-nil, only those dirs are scanned. Otherwise all
+// dirs are scanned. Headers of transcripts unchanged since they were
+// recorded in cache are reused; cache may be nil. Directories and
+// transcripts are read by a bounded pool of workers. Those that cannot be
+// read are returned as FileErrors and left out; transcripts that fail
+// partway are listed with what was read before the failure and returned as
+// partial FileErrors. Results are sorted by timestamp descending (most
+// recent first), then by path. Cancelling ctx stops the scan and returns
+// ctx.Err().
+func FindSessions(ctx context.Context, claudeDir string, projectFilter []string, cache *Cache) ([]SessionInfo, []FileError, error) {
 	var projDirs []string
 
 	if projectFilter != nil {
@@ -61,7 +104,7 @@ func FindSessions(claudeDir string, projectFilter []string) ([]SessionInfo, erro
 	} else {
 		entries, err := os.ReadDir(claudeDir)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		for _, e := range entries {
 			if e.IsDir() {
@@ -70,81 +113,320 @@ func FindSessions(claudeDir string, projectFilter []string) ([]SessionInfo, erro
 		}
 	}
 
-	var sessions []SessionInfo
+	// List the transcripts of every project dir.
+	dirFiles := make([][]string, len(projDirs))
+	dirErrs := make([]error, len(projDirs))
+	Parallel(ctx, len(projDirs), func(i int) {
+		dirFiles[i], dirErrs[i] = listTranscripts(projDirs[i])
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 
-	for _, projDir := range projDirs {
-		jsonlFiles, err := filepath.Glob(filepath.Join(projDir, "*.jsonl"))
-		if err != nil {
+	var problems []FileError
+	var files []string
+	for i, projDir := range projDirs {
+		if dirErrs[i] != nil {
+			problems = append(problems, FileError{Path: projDir, Err: dirErrs[i]})
 			continue
 		}
+		files = append(files, dirFiles[i]...)
+	}
 
-		projName := filepath.Base(projDir)
-		// Convert project dir name back to readable path: replace - with /, strip leading /
-		readable := strings.TrimLeft(strings.ReplaceAll(projName, "-", "/"), "/")
+	// Read the header of every transcript, from the cache when unchanged.
+	headers := make([]header, len(files))
+	fileErrs := make([]error, len(files))
+	readErrs := make([]error, len(files))
+	Parallel(ctx, len(files), func(i int) {
+		info, err := os.Stat(files[i])
+		if err != nil {
+			fileErrs[i] = err
+			return
+		}
+		h, ok := cache.lookup(files[i], info)
+		if !ok {
+			if h, err = scanSessionFile(files[i]); err != nil {
+				fileErrs[i] = err
+				return
+			}
+			cache.store(files[i], info, h)
+		}
+		// Partial headers are cached with their warning, so that it is
+		// reported on every run until the file is fixed.
+		if h.Warning != "" {
+			readErrs[i] = errors.New(h.Warning)
+		}
+		headers[i] = h
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 
-		for _, jf := range jsonlFiles {
-			title, firstTS := scanSessionFile(jf)
+	var sessions []SessionInfo
+	seen := make(map[string]bool, len(files))
+	for i, jf := range files {
+		seen[jf] = true
+		if fileErrs[i] != nil {
+			problems = append(problems, FileError{Path: jf, Err: fileErrs[i]})
+			continue
+		}
+		if readErrs[i] != nil {
+			problems = append(problems, FileError{Path: jf, Err: readErrs[i], Partial: true})
+		}
+		h := headers[i]
+		projName := filepath.Base(filepath.Dir(jf))
+		readable := ProjectName(projName)
 
-			sessions = append(sessions, SessionInfo{
-				Title:      title,
-				SessionID:  strings.TrimSuffix(filepath.Base(jf), ".jsonl"),
-				Project:    readable,
-				ProjectDir: projName,
-				FilePath:   jf,
-				Timestamp:  firstTS,
-			})
+		sessions = append(sessions, SessionInfo{
+			Title:      h.Title,
+			SessionID:  strings.TrimSuffix(filepath.Base(jf), ".jsonl"),
+			Project:    readable,
+			ProjectDir: projName,
+			FilePath:   jf,
+			Timestamp:  h.First,
+			LastTime:   h.Last,
+			UserCount:  h.Users,
+			ReplyCount: h.Replies,
+			Model:      h.Model,
+			CWD:        h.CWD,
+			GitBranch:  h.Branch,
+			Prompt:     h.Prompt,
+		})
+	}
+	for i, projDir := range projDirs {
+		if dirErrs[i] == nil {
+			cache.prune(projDir, seen)
 		}
 	}
 
-	// Sort by timestamp descending (most recent first)
+	// Sort by timestamp descending (most recent first), by path on ties so
+	// that the order does not depend on scheduling.
 	sort.Slice(sessions, func(i, j int) bool {
-		return sessions[i].Timestamp > sessions[j].Timestamp
+		if sessions[i].Timestamp != sessions[j].Timestamp {
+			return sessions[i].Timestamp > sessions[j].Timestamp
+		}
+		return sessions[i].FilePath < sessions[j].FilePath
 	})
 
-	return sessions, nil
+	return sessions, problems, nil
 }
 
-// scanSessionFile reads a .jsonl file line-by-line looking for custom-title and first timestamp.
-func scanSessionFile(path string) (title, firstTS string) {
+// listTranscripts returns the main transcripts of a project dir, skipping
+// subagent transcripts.
+func listTranscripts(projDir string) ([]string, error) {
+	entries, err := os.ReadDir(projDir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+			continue
+		}
+		path := filepath.Join(projDir, e.Name())
+		if !parser.IsAgentFile(path) {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// scanWorkers is the number of directories or transcripts read at once.
+// Scanning is mostly waiting on the disk, so it uses more workers than CPUs.
+var scanWorkers = min(2*runtime.GOMAXPROCS(0), 16)
+
+// Parallel calls f(i) for each i in [0, n) on at most scanWorkers
+// goroutines and waits for them. Once ctx is cancelled, remaining calls are
+// skipped. Callers outside the package use it to read the transcripts found
+// by FindSessions.
+func Parallel(ctx context.Context, n int, f func(i int)) {
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(scanWorkers, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				f(i)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(next)
+	for i := range n {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// header is what listing needs to know about a transcript.
+type header struct {
+	Title   string `json:"title"`
+	First   string `json:"first"`
+	Last    string `json:"last"`
+	Users   int    `json:"users"`
+	Replies int    `json:"replies"`
+	Model   string `json:"model,omitempty"`
+	CWD     string `json:"cwd,omitempty"`
+	Branch  string `json:"branch,omitempty"`
+	Prompt  string `json:"prompt,omitempty"`
+	Warning string `json:"warning,omitempty"` // why reading stopped partway
+}
+
+// maxPrompt is the length in bytes at which a first prompt is cut in the
+// index.
+const maxPrompt = 200
+
+// headerLine holds the fields of a transcript line that a header is made
+// of. Content blocks are decoded without their inputs, results or image
+// data.
+type headerLine struct {
+	Type        string `json:"type"`
+	Timestamp   string `json:"timestamp"`
+	IsSidechain bool   `json:"isSidechain"`
+	CWD         string `json:"cwd"`
+	GitBranch   string `json:"gitBranch"`
+	CustomTitle string `json:"customTitle"`
+	Snapshot    *struct {
+		Timestamp string `json:"timestamp"`
+	} `json:"snapshot"`
+	Message *struct {
+		Model   string          `json:"model"`
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+// headerBlock is a content block as far as a header needs it.
+type headerBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// scanSessionFile reads a .jsonl file for its title, time span, message
+// counts, model, working directory, git branch and first prompt. Lines are
+// decoded only as far as these need, without building the conversation, so
+// the counts include abandoned branches. If reading stops partway, for
+// example at a line too long to scan, the header describes the lines before
+// that point and Warning says why.
+func scanSessionFile(path string) (header, error) {
+	h := header{Title: "(untitled)"}
 	f, err := os.Open(path)
 	if err != nil {
-		return "(untitled)", ""
+		return h, err
 	}
 	defer f.Close()
 
 	scanner := bufio.NewScanner(f)
-	// Allow large lines (some JSONL entries can be big with base64 images)
-	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
-
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024) // 10 MB max line, as in parser.ParseFile
 	for scanner.Scan() {
-		var obj map[string]any
-		if err := json.Unmarshal(scanner.Bytes(), &obj); err != nil {
+		var line headerLine
+		if json.Unmarshal(scanner.Bytes(), &line) != nil {
 			continue
 		}
-
-		if t, ok := obj["type"].(string); ok && t == "custom-title" {
-			if ct, ok := obj["customTitle"].(string); ok {
-				title = ct
+		if line.Type == parser.EntryCustomTitle && line.CustomTitle != "" {
+			h.Title = line.CustomTitle
+		}
+		if h.CWD == "" {
+			h.CWD = line.CWD
+		}
+		if line.IsSidechain {
+			continue
+		}
+		if line.GitBranch != "" {
+			h.Branch = line.GitBranch
+		}
+		ts := line.Timestamp
+		if ts == "" && line.Snapshot != nil {
+			ts = line.Snapshot.Timestamp
+		}
+		if ts != "" {
+			if h.First == "" {
+				h.First = ts
+			}
+			h.Last = ts
+		}
+		if line.Message == nil {
+			continue
+		}
+		switch line.Type {
+		case parser.EntryUser:
+			if text, ok := promptText(line.Message.Content); ok {
+				h.Users++
+				if h.Prompt == "" {
+					h.Prompt = firstLine(text)
+				}
+			}
+		case parser.EntryAssistant:
+			if h.Model == "" {
+				h.Model = line.Message.Model
+			}
+			if hasText(line.Message.Content) {
+				h.Replies++
 			}
 		}
+	}
+	if err := scanner.Err(); err != nil {
+		h.Warning = err.Error()
+	}
+	return h, nil
+}
 
-		if firstTS == "" {
-			if ts, ok := obj["timestamp"].(string); ok && ts != "" {
-				firstTS = ts
-			} else if snap, ok := obj["snapshot"].(map[string]any); ok {
-				if ts, ok := snap["timestamp"].(string); ok && ts != "" {
-					firstTS = ts
+// promptText reports whether user content is a prompt rather than tool
+// results or system content, and returns its first text.
+func promptText(raw json.RawMessage) (string, bool) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		s = strings.TrimSpace(s)
+		return s, s != "" && !parser.IsSystemContent(s)
+	}
+	var blocks []headerBlock
+	if json.Unmarshal(raw, &blocks) != nil {
+		return "", false
+	}
+	text, ok := "", false
+	for _, b := range blocks {
+		switch b.Type {
+		case parser.BlockText:
+			if t := strings.TrimSpace(b.Text); t != "" && !parser.IsSystemContent(t) {
+				if !ok || text == "" {
+					text = t
 				}
+				ok = true
 			}
+		case parser.BlockImage:
+			ok = true
 		}
+	}
+	return text, ok
+}
 
-		if title != "" && firstTS != "" {
-			break
+// hasText reports whether assistant content has a non-empty text block.
+func hasText(raw json.RawMessage) bool {
+	var blocks []headerBlock
+	if json.Unmarshal(raw, &blocks) != nil {
+		var s string
+		return json.Unmarshal(raw, &s) == nil && strings.TrimSpace(s) != ""
+	}
+	for _, b := range blocks {
+		if b.Type == parser.BlockText && strings.TrimSpace(b.Text) != "" {
+			return true
 		}
 	}
+	return false
+}
 
-	if title == "" {
-		title = "(untitled)"
+// firstLine returns the first non-empty line of s, cut to maxPrompt bytes.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if len(line) > maxPrompt {
+				line = strings.ToValidUTF8(line[:maxPrompt], "")
+			}
+			return line
+		}
 	}
-	return title, firstTS
+	return ""
 }
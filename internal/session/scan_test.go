@@ -0,0 +1,22 @@
+package session
+
+import "testing"
+
+func TestPathToProjectDir(t *testing.T) {
+	tests := []struct {
+		path, dir, name string
+	}{
+		{"/Users/alice/projects/demo", "-Users-alice-projects-demo", "Users/alice/projects/demo"},
+		{"/Users/first.last/app", "-Users-first-last-app", "Users/first/last/app"},
+		{"/home/first_last/value_slim_demo", "-home-first-last-value-slim-demo", "home/first/last/value/slim/demo"},
+		{"/srv/my app/v2.1", "-srv-my-app-v2-1", "srv/my/app/v2/1"},
+	}
+	for _, tt := range tests {
+		if got := PathToProjectDir(tt.path); got != tt.dir {
+			t.Errorf("PathToProjectDir(%q) = %q, want %q", tt.path, got, tt.dir)
+		}
+		if got := ProjectName(tt.dir); got != tt.name {
+			t.Errorf("ProjectName(%q) = %q, want %q", tt.dir, got, tt.name)
+		}
+	}
+}
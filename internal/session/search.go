@@ -1,13 +1,34 @@
 package session
 
-import "strings"
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxCandidates is the number of sessions FindByQuery returns when no
+// session clearly matches best.
+const MaxCandidates = 10
+
+// Candidate is a session and how well it matches a query.
+type Candidate struct {
+	Session SessionInfo
+	Score   float64
+}
 
 // FindByQuery searches sessions by query string.
-// Priority: exact title match (case-insensitive) -> UUID prefix match -> title substring -> project substring.
-// Returns (match, nil) if exactly one match found, or (nil, ambiguous) if multiple matches found.
-// Returns (nil, nil) if no match found.
+// An exact title (case-insensitive) or a unique UUID prefix is a match.
+// Otherwise sessions are ranked by Rank, and the best one is a match if it
+// clearly beats the next. Returns (match, nil) on a match, or (nil,
+// candidates) with up to MaxCandidates sessions, best first.
+// Returns (nil, nil) if no session matches.
 func FindByQuery(sessions []SessionInfo, query string) (*SessionInfo, []SessionInfo) {
 	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return nil, nil
+	}
 
 	// Exact title match (case-insensitive)
 	for i := range sessions {
@@ -17,39 +38,223 @@ func FindByQuery(sessions []SessionInfo, query string) (*SessionInfo, []SessionI
 	}
 
 	// UUID prefix match
+	idMatches := withIDPrefix(sessions, q)
+	if len(idMatches) == 1 {
+		return &idMatches[0], nil
+	}
+
+	ranked := Rank(sessions, query, time.Now())
+	if len(ranked) == 0 {
+		if len(idMatches) > 1 {
+			return nil, idMatches[:min(len(idMatches), MaxCandidates)]
+		}
+		return nil, nil
+	}
+	if len(ranked) == 1 || ranked[0].Score >= clearWinner*ranked[1].Score {
+		return &ranked[0].Session, nil
+	}
+	var candidates []SessionInfo
+	for _, c := range ranked[:min(len(ranked), MaxCandidates)] {
+		candidates = append(candidates, c.Session)
+	}
+	return nil, candidates
+}
+
+// FindByID finds a session by its UUID or a prefix of it, ignoring case.
+// Unlike FindByQuery it never guesses: returns (match, nil) if one session's
+// UUID is id or the only one starting with it, (nil, candidates) with up to
+// MaxCandidates sessions if several start with it, and (nil, nil) if none
+// does.
+func FindByID(sessions []SessionInfo, id string) (*SessionInfo, []SessionInfo) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id == "" {
+		return nil, nil
+	}
 	for i := range sessions {
-		if strings.HasPrefix(strings.ToLower(sessions[i].SessionID), q) {
+		if strings.ToLower(sessions[i].SessionID) == id {
 			return &sessions[i], nil
 		}
 	}
+	matches := withIDPrefix(sessions, id)
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &matches[0], nil
+	}
+	return nil, matches[:min(len(matches), MaxCandidates)]
+}
 
-	// Substring match in title
-	var titleMatches []SessionInfo
+// withIDPrefix returns the sessions whose UUID starts with the lowercase
+// prefix.
+func withIDPrefix(sessions []SessionInfo, prefix string) []SessionInfo {
+	var out []SessionInfo
 	for _, s := range sessions {
-		if strings.Contains(strings.ToLower(s.Title), q) {
-			titleMatches = append(titleMatches, s)
+		if strings.HasPrefix(strings.ToLower(s.SessionID), prefix) {
+			out = append(out, s)
 		}
 	}
-	if len(titleMatches) == 1 {
-		return &titleMatches[0], nil
-	}
-	if len(titleMatches) > 1 {
-		return nil, titleMatches
+	return out
+}
+
+// clearWinner is how many times the best score must exceed the second for
+// FindByQuery to pick it.
+const clearWinner = 1.5
+
+// Field weights: a word in the title says more about a session than one in
+// its first prompt or project path.
+const (
+	titleWeight   = 3
+	promptWeight  = 1.5
+	branchWeight  = 1.5
+	projectWeight = 1
+)
+
+// Rank scores sessions against a query and returns those matching every
+// query word, best first. Words may come in any order and match a word of
+// the title, first prompt, git branch or project exactly, as a prefix, as a
+// substring or with a typo. Matches in the title count most; sessions whose
+// title contains the whole query, and recent sessions, get a boost.
+func Rank(sessions []SessionInfo, query string, now time.Time) []Candidate {
+	words := tokenize(query)
+	if len(words) == 0 {
+		return nil
 	}
+	phrase := strings.Join(words, " ")
 
-	// Substring match in project name
-	var projMatches []SessionInfo
+	var out []Candidate
 	for _, s := range sessions {
-		if strings.Contains(strings.ToLower(s.Project), q) {
-			projMatches = append(projMatches, s)
+		fields := []struct {
+			words  []string
+			weight float64
+		}{
+			{tokenize(s.Title), titleWeight},
+			{tokenize(s.Prompt), promptWeight},
+			{tokenize(s.GitBranch), branchWeight},
+			{tokenize(s.Project), projectWeight},
+		}
+
+		score := 0.0
+		for _, w := range words {
+			best := 0.0
+			for _, f := range fields {
+				best = max(best, f.weight*matchWord(w, f.words))
+			}
+			if best == 0 {
+				score = 0
+				break
+			}
+			score += best
+		}
+		if score == 0 {
+			continue
 		}
+		score /= float64(len(words))
+		if strings.Contains(strings.Join(tokenize(s.Title), " "), phrase) {
+			score += 1
+		}
+		out = append(out, Candidate{Session: s, Score: score * recency(s, now)})
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Score > out[j].Score
+	})
+	return out
+}
+
+// recency returns a boost of up to 25% for sessions active in the last few
+// weeks.
+func recency(s SessionInfo, now time.Time) float64 {
+	ts := s.LastTime
+	if ts == "" {
+		ts = s.Timestamp
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return 1
+	}
+	days := max(now.Sub(t).Hours()/24, 0)
+	return 1 + 0.25*math.Exp2(-days/14)
+}
+
+// tokenize splits s into lowercase words of letters and digits.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matchWord returns how well w matches the best of words, from 1 for an
+// equal word down to 0 for none.
+func matchWord(w string, words []string) float64 {
+	best := 0.0
+	for _, f := range words {
+		switch {
+		case f == w:
+			return 1
+		case strings.HasPrefix(f, w):
+			best = max(best, 0.9)
+		case len(w) >= 3 && strings.Contains(f, w):
+			best = max(best, 0.7)
+		default:
+			if d := typos(w); d > 0 && editDistance(w, f, d) <= d {
+				best = max(best, 0.6)
+			}
+		}
+	}
+	return best
+}
+
+// typos returns how many typos a query word of this length may contain.
+func typos(w string) int {
+	switch n := len([]rune(w)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
 	}
-	if len(projMatches) == 1 {
-		return &projMatches[0], nil
+	return 0
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and transpositions of adjacent characters that turn a into b, or limit+1
+// if it exceeds limit.
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > limit {
+		return limit + 1
 	}
-	if len(projMatches) > 1 {
-		return nil, projMatches
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
 	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
 
-	return nil, nil
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
 }
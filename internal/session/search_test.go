@@ -0,0 +1,134 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"oauth", "oauth", 2, 0},
+		{"redirect", "redirct", 2, 1},   // deletion
+		{"redirect", "rediirect", 2, 1}, // insertion
+		{"redirect", "redarect", 2, 1},  // substitution
+		{"redirect", "rediretc", 2, 1},  // transposition
+		{"redirect", "rdeirecr", 2, 2},
+		{"redirect", "direct", 1, 2}, // length difference over the limit
+		{"abcdef", "uvwxyz", 2, 3},   // row minimum over the limit
+		{"", "abc", 3, 3},
+		{"café", "cafe", 1, 1}, // runes, not bytes
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, tt.limit); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.limit, got, tt.want)
+		}
+	}
+}
+
+// testSessions have no timestamps, so that recency does not change their
+// order.
+var testSessions = []SessionInfo{
+	{SessionID: "1a2b3c4d-0000", Title: "Fix OAuth redirect", Project: "home/alice/api", GitBranch: "fix/oauth"},
+	{SessionID: "1a2b9999-0000", Title: "Add billing export", Project: "home/alice/api", Prompt: "export invoices as csv"},
+	{SessionID: "5e6f7a8b-0000", Title: "Refactor redirect handler", Project: "home/alice/web"},
+	{SessionID: "9c0d1e2f-0000", Title: "Untitled", Project: "home/alice/docs", Prompt: "write the oauth guide"},
+}
+
+func TestRank(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		query string
+		want  []string // session ids, best first
+	}{
+		{"oauth", []string{"1a2b3c4d-0000", "9c0d1e2f-0000"}},   // title beats prompt
+		{"redirect oauth", []string{"1a2b3c4d-0000"}},           // every word must match
+		{"redirct", []string{"1a2b3c4d-0000", "5e6f7a8b-0000"}}, // typo
+		{"csv", []string{"1a2b9999-0000"}},                      // first prompt
+		{"web redirect", []string{"5e6f7a8b-0000"}},             // project
+		{"fix/oauth", []string{"1a2b3c4d-0000"}},                // branch, split into words
+		{"refactor handler", []string{"5e6f7a8b-0000"}},         // words in any order
+		{"redir", []string{"1a2b3c4d-0000", "5e6f7a8b-0000"}},   // prefix
+		{"kubernetes", nil},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		got := Rank(testSessions, tt.query, now)
+		var ids []string
+		for _, c := range got {
+			ids = append(ids, c.Session.SessionID)
+		}
+		if !equal(ids, tt.want) {
+			t.Errorf("Rank(%q) = %v, want %v", tt.query, ids, tt.want)
+		}
+	}
+}
+
+func TestFindByQuery(t *testing.T) {
+	tests := []struct {
+		query      string
+		match      string // session id, or "" for none
+		candidates []string
+	}{
+		{"fix oauth redirect", "1a2b3c4d-0000", nil}, // exact title, ignoring case
+		{"5e6f", "5e6f7a8b-0000", nil},               // unique id prefix
+		{"billing", "1a2b9999-0000", nil},            // clear winner
+		{"redirect", "", []string{"1a2b3c4d-0000", "5e6f7a8b-0000"}},
+		{"1a2b", "", []string{"1a2b3c4d-0000", "1a2b9999-0000"}}, // ambiguous id prefix
+		{"kubernetes", "", nil},
+		{"", "", nil},
+	}
+	for _, tt := range tests {
+		match, candidates := FindByQuery(testSessions, tt.query)
+		checkFind(t, "FindByQuery", tt.query, match, candidates, tt.match, tt.candidates)
+	}
+}
+
+func TestFindByID(t *testing.T) {
+	tests := []struct {
+		id         string
+		match      string
+		candidates []string
+	}{
+		{"1a2b3c4d-0000", "1a2b3c4d-0000", nil},
+		{"1A2B3C", "1a2b3c4d-0000", nil},
+		{"1a2b", "", []string{"1a2b3c4d-0000", "1a2b9999-0000"}},
+		{"billing", "", nil}, // no fuzzy matching
+		{"5e6g", "", nil},    // no typos either
+		{"", "", nil},
+	}
+	for _, tt := range tests {
+		match, candidates := FindByID(testSessions, tt.id)
+		checkFind(t, "FindByID", tt.id, match, candidates, tt.match, tt.candidates)
+	}
+}
+
+func checkFind(t *testing.T, fn, query string, match *SessionInfo, candidates []SessionInfo, wantMatch string, wantCandidates []string) {
+	t.Helper()
+	got := ""
+	if match != nil {
+		got = match.SessionID
+	}
+	var ids []string
+	for _, c := range candidates {
+		ids = append(ids, c.SessionID)
+	}
+	if got != wantMatch || !equal(ids, wantCandidates) {
+		t.Errorf("%s(%q) = %q, %v; want %q, %v", fn, query, got, ids, wantMatch, wantCandidates)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -8,4 +8,11 @@ type SessionInfo struct {
 	ProjectDir string // encoded: "-Users-mohamed-projects-foo"
 	FilePath   string // absolute path to .jsonl
 	Timestamp  string // ISO 8601 from first entry
+	LastTime   string // ISO 8601 from last entry
+	UserCount  int    // user prompts on the latest branch
+	ReplyCount int    // assistant replies with text on the latest branch
+	Model      string // model id of the first main-thread reply
+	CWD        string // working directory of the session
+	GitBranch  string // git branch last recorded on the main thread
+	Prompt     string // first line of the first prompt
 }
@@ -0,0 +1,254 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/HabibPro1999/shiplog/internal/parser"
+	"github.com/HabibPro1999/shiplog/internal/pricing"
+	"github.com/HabibPro1999/shiplog/internal/session"
+)
+
+// Grouping keys for Aggregate.
+const (
+	ByProject = "project"
+	ByModel   = "model"
+	ByWeek    = "week"
+	ByDay     = "day"
+)
+
+// SessionStats summarises the activity and usage of one session.
+type SessionStats struct {
+	Session  session.SessionInfo
+	Start    time.Time // zero if the session has no timestamp
+	Activity []parser.Activity
+	Usage    []parser.ModelUsage // priced
+}
+
+// Collect parses a session and tallies its activity and priced usage.
+func Collect(info session.SessionInfo, table pricing.Table) (SessionStats, error) {
+	t, err := parser.ParseSession(info.FilePath)
+	if err != nil {
+		return SessionStats{}, err
+	}
+	meta := parser.ExtractMeta(t.Entries)
+	table.Estimate(&meta)
+	return SessionStats{
+		Session:  info,
+		Start:    ParseTime(info.Timestamp),
+		Activity: parser.CountActivity(t.Entries),
+		Usage:    meta.Usage,
+	}, nil
+}
+
+// Group is the aggregate of the sessions sharing a grouping key.
+type Group struct {
+	Key       string         `json:"key"`
+	Sessions  int            `json:"sessions"`
+	Days      int            `json:"active_days"`
+	Prompts   int            `json:"prompts"`
+	Replies   int            `json:"replies"`
+	ToolCalls map[string]int `json:"tool_calls"`
+	Tokens    Tokens         `json:"tokens"`
+	Cost      float64        `json:"estimated_cost_usd"`
+	Unpriced  bool           `json:"has_unpriced_models"`
+
+	sessions map[string]bool
+	days     map[string]bool
+}
+
+// addDay records the day of t as active. Sessions without a date count
+// towards no day.
+func (g *Group) addDay(t time.Time) {
+	if !t.IsZero() {
+		g.days[t.Format("2006-01-02")] = true
+	}
+}
+
+// Tokens is the JSON form of parser.TokenUsage.
+type Tokens struct {
+	Input         int `json:"input"`
+	Output        int `json:"output"`
+	CacheCreation int `json:"cache_creation"`
+	CacheRead     int `json:"cache_read"`
+	Total         int `json:"total"`
+}
+
+// SessionsPerDay returns the average number of sessions on each active day.
+func (g Group) SessionsPerDay() float64 {
+	if g.Days == 0 {
+		return 0
+	}
+	return float64(g.Sessions) / float64(g.Days)
+}
+
+// ToolCallTotal returns the number of tool calls across all tools.
+func (g Group) ToolCallTotal() int {
+	n := 0
+	for _, c := range g.ToolCalls {
+		n += c
+	}
+	return n
+}
+
+// Aggregate groups session stats by project, model, ISO week or day.
+// Groups are sorted by key, except projects, which are sorted by session count.
+// When grouping by model, a session counts towards every model it used.
+func Aggregate(all []SessionStats, by string) ([]Group, error) {
+	groups := make(map[string]*Group)
+	get := func(key string) *Group {
+		g, ok := groups[key]
+		if !ok {
+			g = &Group{
+				Key:       key,
+				ToolCalls: make(map[string]int),
+				sessions:  make(map[string]bool),
+				days:      make(map[string]bool),
+			}
+			groups[key] = g
+		}
+		return g
+	}
+
+	for _, s := range all {
+		day := "unknown"
+		if !s.Start.IsZero() {
+			day = s.Start.Format("2006-01-02")
+		}
+
+		var key string
+		switch by {
+		case ByProject:
+			key = s.Session.Project
+		case ByDay:
+			key = day
+		case ByWeek:
+			key = "unknown"
+			if !s.Start.IsZero() {
+				year, week := s.Start.ISOWeek()
+				key = fmt.Sprintf("%d-W%02d", year, week)
+			}
+		case ByModel:
+		default:
+			return nil, fmt.Errorf("unknown grouping %q (want project, model, week or day)", by)
+		}
+
+		for _, a := range s.Activity {
+			k := key
+			if by == ByModel {
+				k = a.Model
+			}
+			g := get(k)
+			g.sessions[s.Session.SessionID] = true
+			g.addDay(s.Start)
+			g.Prompts += a.Prompts
+			g.Replies += a.Replies
+			for tool, n := range a.ToolCalls {
+				g.ToolCalls[tool] += n
+			}
+		}
+		for _, u := range s.Usage {
+			k := key
+			if by == ByModel {
+				k = u.Model
+			}
+			g := get(k)
+			g.sessions[s.Session.SessionID] = true
+			g.addDay(s.Start)
+			g.Tokens.Input += u.Tokens.Input
+			g.Tokens.Output += u.Tokens.Output
+			g.Tokens.CacheCreation += u.Tokens.CacheCreation
+			g.Tokens.CacheRead += u.Tokens.CacheRead
+			g.Tokens.Total += u.Tokens.Total()
+			g.Cost += u.Cost
+			if !u.Priced && u.Tokens.Total() > 0 {
+				g.Unpriced = true
+			}
+		}
+		if len(s.Activity) == 0 && len(s.Usage) == 0 && by != ByModel {
+			g := get(key)
+			g.sessions[s.Session.SessionID] = true
+			g.addDay(s.Start)
+		}
+	}
+
+	result := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		g.Sessions = len(g.sessions)
+		g.Days = len(g.days)
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if by == ByProject && result[i].Sessions != result[j].Sessions {
+			return result[i].Sessions > result[j].Sessions
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result, nil
+}
+
+// Total combines groups into a single summary row keyed "total". Session
+// and day counts are taken from the sessions themselves, so sessions that
+// appear in several model groups are only counted once.
+func Total(all []SessionStats, groups []Group) Group {
+	t := Group{Key: "total", ToolCalls: make(map[string]int)}
+	days := make(map[string]bool)
+	for _, s := range all {
+		if !s.Start.IsZero() {
+			days[s.Start.Format("2006-01-02")] = true
+		}
+	}
+	t.Sessions = len(all)
+	t.Days = len(days)
+	for _, g := range groups {
+		t.Prompts += g.Prompts
+		t.Replies += g.Replies
+		for tool, n := range g.ToolCalls {
+			t.ToolCalls[tool] += n
+		}
+		t.Tokens.Input += g.Tokens.Input
+		t.Tokens.Output += g.Tokens.Output
+		t.Tokens.CacheCreation += g.Tokens.CacheCreation
+		t.Tokens.CacheRead += g.Tokens.CacheRead
+		t.Tokens.Total += g.Tokens.Total
+		t.Cost += g.Cost
+		t.Unpriced = t.Unpriced || g.Unpriced
+	}
+	return t
+}
+
+// ToolCount is a tool name and its number of calls.
+type ToolCount struct {
+	Tool  string `json:"tool"`
+	Calls int    `json:"calls"`
+}
+
+// TopTools returns tool call counts sorted by calls, descending.
+func TopTools(calls map[string]int) []ToolCount {
+	var out []ToolCount
+	for tool, n := range calls {
+		out = append(out, ToolCount{Tool: tool, Calls: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Calls != out[j].Calls {
+			return out[i].Calls > out[j].Calls
+		}
+		return out[i].Tool < out[j].Tool
+	})
+	return out
+}
+
+// ParseTime parses a transcript timestamp into local time, returning the
+// zero time if it cannot be parsed.
+func ParseTime(ts string) time.Time {
+	if ts == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(ts))
+	if err != nil {
+		return time.Time{}
+	}
+	return t.Local()
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/HabibPro1999/shiplog/internal/parser"
+	"github.com/HabibPro1999/shiplog/internal/picker"
+	"github.com/HabibPro1999/shiplog/internal/pricing"
+	"github.com/HabibPro1999/shiplog/internal/redact"
 	"github.com/HabibPro1999/shiplog/internal/render"
 	"github.com/HabibPro1999/shiplog/internal/session"
 	pflag "github.com/spf13/pflag"
@@ -19,19 +25,60 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "stats":
+			runStats(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "search":
+			runSearch(os.Args[2:])
+			return
+		case "schema":
+			os.Stdout.Write(render.JSONSchema)
+			return
+		}
+	}
+
 	var (
 		showAll   bool
 		output    string
 		sessionID string
 		list      bool
 		showVer   bool
+		thinking  string
+		branch    string
+		prices    string
+		format    string
+		inline    bool
+		theme     string
+		tmplDir   string
+		redactOn  bool
+		redactCfg string
+		anonymize bool
+		anonCfg   string
+		reindex   bool
 	)
 
 	pflag.BoolVarP(&showAll, "all", "a", false, "Show all sessions (ignore project context)")
-	pflag.StringVarP(&output, "output", "o", "", "Output HTML file path")
+	pflag.StringVarP(&output, "output", "o", "", "Output file path")
 	pflag.StringVar(&sessionID, "session-id", "", "Export by session UUID")
-	pflag.BoolVarP(&list, "list", "l", false, "List sessions")
+	pflag.BoolVarP(&list, "list", "l", false, "List sessions instead of opening the picker")
 	pflag.BoolVarP(&showVer, "version", "v", false, "Show version")
+	pflag.StringVar(&thinking, "thinking", render.ThinkingHide, "Thinking blocks: hide, collapsed or show")
+	pflag.StringVar(&branch, "branch", parser.BranchLatest, "Conversation branch: latest, all, or an entry UUID")
+	pflag.StringVar(&prices, "pricing", "", "Pricing table JSON (default: "+pricing.DefaultPath()+" if present)")
+	pflag.StringVar(&format, "format", render.FormatHTML, "Export format: html, md or json")
+	pflag.BoolVar(&inline, "inline-images", false, "Markdown: embed images as data URIs instead of sibling files")
+	pflag.StringVar(&theme, "theme", render.ThemeAuto, "HTML theme: "+strings.Join(render.Themes(), ", "))
+	pflag.StringVar(&tmplDir, "template", "", "HTML template directory with chat.html and/or .css files")
+	pflag.BoolVar(&redactOn, "redact", false, "Replace secrets and email addresses with placeholders")
+	pflag.StringVar(&redactCfg, "redact-config", "", "Redaction patterns JSON (default: "+redact.DefaultPath()+" if present)")
+	pflag.BoolVar(&anonymize, "anonymize", false, "Replace home directory, user name, host name and internal names")
+	pflag.StringVar(&anonCfg, "anonymize-config", "", "Internal names JSON (default: "+redact.AnonymizePath()+" if present)")
+	pflag.BoolVar(&reindex, "reindex", false, "Rebuild the session index instead of reusing cached headers")
 	pflag.Parse()
 
 	if showVer {
@@ -39,137 +86,472 @@ func main() {
 		os.Exit(0)
 	}
 
-	query := pflag.Arg(0)
-
-	// Determine claude projects directory
-	home, err := os.UserHomeDir()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "  Error: cannot determine home directory: %v\n", err)
+	switch thinking {
+	case render.ThinkingHide, render.ThinkingCollapsed, render.ThinkingShow:
+	default:
+		fmt.Fprintf(os.Stderr, "  Error: invalid --thinking value %q (want hide, collapsed or show)\n", thinking)
 		os.Exit(1)
 	}
-	claudeDir := filepath.Join(home, ".claude", "projects")
 
-	// Determine project scope
-	var projectFilter []string
-	scopeLabel := "all projects"
-	if !showAll {
-		matchingDirs := session.ProjectDirsForCWD(claudeDir)
-		if len(matchingDirs) > 0 {
-			projectFilter = matchingDirs
-			cwd, _ := os.Getwd()
-			scopeLabel = cwd
-		}
+	switch format {
+	case render.FormatHTML, render.FormatMarkdown, render.FormatJSON:
+	default:
+		fmt.Fprintf(os.Stderr, "  Error: invalid --format value %q (want html, md or json)\n", format)
+		os.Exit(1)
 	}
 
+	checkTheme(theme, tmplDir)
+	redactor := loadRedactor(redactOn, redactCfg)
+	anonymizer := loadAnonymizer(anonymize, anonCfg)
+
+	query := pflag.Arg(0)
+
+	claudeDir := claudeProjectsDir()
+	projectFilter, scopeLabel := projectScope(claudeDir, showAll)
+
+	index := loadIndex(reindex)
 	fmt.Printf("  Scanning sessions (%s)...\n", scopeLabel)
-	sessions, err := session.FindSessions(claudeDir, projectFilter)
+	sessions, err := findSessions(claudeDir, projectFilter, index)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "  Error scanning sessions: %v\n", err)
 		os.Exit(1)
 	}
 
-	// List mode: -l flag, or no query and no session-id
+	cfg := exportConfig{
+		output:     output,
+		thinking:   thinking,
+		branch:     branch,
+		prices:     prices,
+		format:     format,
+		inline:     inline,
+		theme:      theme,
+		tmplDir:    tmplDir,
+		redactor:   redactor,
+		anonymizer: anonymizer,
+	}
+
+	// List mode: -l flag, or no query and no session-id. On a terminal,
+	// without -l, the sessions are shown in the picker instead.
 	if list || (query == "" && sessionID == "") {
 		if len(sessions) == 0 && projectFilter != nil {
 			fmt.Println("  No sessions found for this project. Use -a to show all.")
-		} else {
+		} else if list || len(sessions) == 0 || !picker.Available() {
 			listSessions(sessions)
+		} else if match := pickSession(sessions, ""); match != nil {
+			exportMatch(*match, cfg)
 		}
 		return
 	}
 
-	// Export mode
-	q := sessionID
-	if q == "" {
-		q = query
+	// Export mode. A session id must match exactly or as a unique prefix;
+	// only the free-text query is matched fuzzily.
+	q, find := query, session.FindByQuery
+	if sessionID != "" {
+		q, find = sessionID, session.FindByID
 	}
 
-	match, ambiguous := session.FindByQuery(sessions, q)
+	match, ambiguous := find(sessions, q)
 
 	// If scoped search fails, retry with all projects
 	if match == nil && len(ambiguous) == 0 && projectFilter != nil {
 		fmt.Println("  Not found in current project, searching all...")
-		sessions, err = session.FindSessions(claudeDir, nil)
+		sessions, err = findSessions(claudeDir, nil, index)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "  Error scanning sessions: %v\n", err)
 			os.Exit(1)
 		}
-		match, ambiguous = session.FindByQuery(sessions, q)
+		match, ambiguous = find(sessions, q)
+	}
+
+	if match == nil && len(ambiguous) > 0 && sessionID == "" && picker.Available() {
+		if match = pickSession(sessions, q); match == nil {
+			return
+		}
 	}
 
 	if match == nil {
 		if len(ambiguous) > 0 {
-			fmt.Printf("  Multiple sessions match '%s':\n", q)
-			for _, m := range ambiguous {
-				fmt.Printf("    - %s (%s)\n", m.Title, m.Project)
+			fmt.Printf("  Multiple sessions match '%s', best first:\n", q)
+			for i, m := range ambiguous {
+				shortID := m.SessionID
+				if len(shortID) > 8 {
+					shortID = shortID[:8]
+				}
+				fmt.Printf("    %2d. %s  %s  (%s)\n", i+1, m.Title, shortID, m.Project)
 			}
-			fmt.Println("  Be more specific.")
+			fmt.Println("  Be more specific, or pick one with --session-id.")
+		} else if sessionID != "" {
+			fmt.Printf("  No session found with id '%s'\n", q)
 		} else {
 			fmt.Printf("  No session found matching '%s'\n", q)
 		}
 		os.Exit(1)
 	}
 
-	fmt.Printf("  Found: \"%s\" (%s)\n", match.Title, match.Project)
-	fmt.Println("  Parsing transcript...")
+	exportMatch(*match, cfg)
+}
 
-	entries, err := parser.ParseFile(match.FilePath)
+// pickSession lets the user choose a session in the terminal, starting
+// from filter. It returns nil if they cancel.
+func pickSession(sessions []session.SessionInfo, filter string) *session.SessionInfo {
+	match, err := picker.Run(sessions, filter)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "  Error parsing JSONL: %v\n", err)
+		fmt.Fprintf(os.Stderr, "  Error in session picker: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Printf("  %d entries\n", len(entries))
-
-	messages := parser.BuildMessages(entries)
-	meta := parser.ExtractMeta(entries)
+	if match == nil {
+		fmt.Println("  Cancelled.")
+		return nil
+	}
+	return match
+}
 
-	userCount := 0
-	assistantCount := 0
-	for _, m := range messages {
-		switch m.Role {
-		case "user":
-			userCount++
-		case "assistant":
-			assistantCount++
+// checkTheme exits if theme is not a built-in theme or tmplDir, when set,
+// is not a usable template directory.
+func checkTheme(theme, tmplDir string) {
+	if !slices.Contains(render.Themes(), theme) {
+		fmt.Fprintf(os.Stderr, "  Error: invalid --theme value %q (want %s)\n", theme, strings.Join(render.Themes(), ", "))
+		os.Exit(1)
+	}
+	if tmplDir != "" {
+		if err := render.ValidateTemplate(tmplDir); err != nil {
+			fmt.Fprintf(os.Stderr, "  Error in --template: %v\n", err)
+			os.Exit(1)
 		}
 	}
-	fmt.Printf("  %d user messages, %d assistant messages\n", userCount, assistantCount)
+}
+
+// loadRedactor returns the redactor for --redact, or nil if it is off,
+// exiting if the patterns at path cannot be loaded.
+func loadRedactor(on bool, path string) *redact.Redactor {
+	if !on {
+		return nil
+	}
+	detectors, err := redact.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error loading redaction patterns: %v\n", err)
+		os.Exit(1)
+	}
+	return redact.New(detectors)
+}
+
+// loadAnonymizer returns the anonymizer for --anonymize, or nil if it is
+// off, exiting if the internal names at path cannot be loaded.
+func loadAnonymizer(on bool, path string) *redact.Anonymizer {
+	if !on {
+		return nil
+	}
+	names, err := redact.LoadNames(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error loading internal names: %v\n", err)
+		os.Exit(1)
+	}
+	return redact.NewAnonymizer(redact.CurrentIdentity(), names)
+}
+
+// exportConfig holds the options of a single-session export.
+type exportConfig struct {
+	output     string // empty: derived from the title
+	thinking   string
+	branch     string
+	prices     string
+	format     string
+	inline     bool
+	theme      string
+	tmplDir    string
+	redactor   *redact.Redactor   // nil: no redaction
+	anonymizer *redact.Anonymizer // nil: no anonymization
+}
+
+// exportMatch renders one session to a file, exiting on failure.
+func exportMatch(match session.SessionInfo, cfg exportConfig) {
+	fmt.Printf("  Found: \"%s\" (%s)\n", match.Title, match.Project)
+	fmt.Println("  Parsing transcript...")
+
+	transcript, err := parser.ParseSession(match.FilePath)
+	if transcript == nil {
+		fmt.Fprintf(os.Stderr, "  Error parsing JSONL: %v\n", err)
+		os.Exit(1)
+	}
+	if err != nil {
+		warnPartialRead("transcript", err)
+	}
+	fmt.Printf("  %d entries\n", len(transcript.Entries))
+	reportParseIssues(transcript)
 
-	fmt.Println("  Generating HTML...")
-	htmlBytes, err := render.Generate(messages, meta, match.Project)
+	messages, err := parser.BuildConversation(transcript.Entries, cfg.branch)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "  Error generating HTML: %v\n", err)
+		fmt.Fprintf(os.Stderr, "  Error selecting branch: %v\n", err)
 		os.Exit(1)
 	}
+	meta := parser.ExtractMeta(transcript.Entries)
+	project := match.Project
+	// The output file is named after the title, which may hold secrets too.
+	title := match.Title
+	if cfg.redactor != nil {
+		messages = redactSession(cfg.redactor, messages, &meta)
+		title = cfg.redactor.String(title)
+		reportRedactions(cfg.redactor.Summary())
+	}
+	if cfg.anonymizer != nil {
+		messages, project = anonymizeSession(cfg.anonymizer, messages, &meta, project)
+		title = cfg.anonymizer.String(title)
+	}
+	priceTable, err := pricing.Load(cfg.prices)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error loading pricing: %v\n", err)
+		os.Exit(1)
+	}
+	priceTable.Estimate(&meta)
+
+	userCount, assistantCount := render.CountMessages(messages)
+	fmt.Printf("  %d user messages, %d assistant messages\n", userCount, assistantCount)
+	if len(meta.Usage) > 0 {
+		cost, priced := meta.TotalCost()
+		costLabel := fmt.Sprintf("$%.2f", cost)
+		if !priced {
+			costLabel += " (some models unpriced)"
+		}
+		fmt.Printf("  %d tokens, estimated cost %s\n", meta.TotalTokens().Total(), costLabel)
+	}
 
 	// Determine output path
-	outputPath := output
+	outputPath := cfg.output
 	if outputPath == "" {
-		safeTitle := strings.ReplaceAll(match.Title, " ", "_")
+		safeTitle := strings.ReplaceAll(title, " ", "_")
 		safeTitle = strings.ReplaceAll(safeTitle, "/", "-")
-		outputPath = safeTitle + ".html"
+		outputPath = safeTitle + "." + cfg.format
 	}
 
-	if err := os.WriteFile(outputPath, htmlBytes, 0644); err != nil {
+	opts := render.Options{Thinking: cfg.thinking, Theme: cfg.theme, Template: cfg.tmplDir}
+	var (
+		data   []byte
+		assets []render.Asset
+	)
+	switch cfg.format {
+	case render.FormatMarkdown:
+		fmt.Println("  Generating Markdown...")
+		if !cfg.inline {
+			opts.AssetDir = strings.TrimSuffix(filepath.Base(outputPath), filepath.Ext(outputPath)) + "_files"
+		}
+		data, assets, err = render.GenerateMarkdown(messages, meta, project, opts)
+	case render.FormatJSON:
+		fmt.Println("  Generating JSON...")
+		data, err = render.GenerateJSON(messages, meta, project, opts)
+	default:
+		fmt.Println("  Generating HTML...")
+		data, err = render.Generate(messages, meta, project, opts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error generating output: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
 		fmt.Fprintf(os.Stderr, "  Error writing file: %v\n", err)
 		os.Exit(1)
 	}
+	for _, a := range assets {
+		p := filepath.Join(filepath.Dir(outputPath), filepath.FromSlash(a.Name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "  Error writing file: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(p, a.Data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "  Error writing file: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	sizeMB := float64(len(htmlBytes)) / (1024 * 1024)
+	sizeMB := float64(len(data)) / (1024 * 1024)
 	fmt.Printf("  Written to: %s (%.1f MB)\n", outputPath, sizeMB)
+	if len(assets) > 0 {
+		noun := "images"
+		if len(assets) == 1 {
+			noun = "image"
+		}
+		fmt.Printf("  %d %s written to %s\n", len(assets), noun, filepath.Join(filepath.Dir(outputPath), opts.AssetDir))
+	}
 	fmt.Println("  Done.")
 }
 
+// claudeProjectsDir returns ~/.claude/projects, exiting if the home
+// directory cannot be determined.
+func claudeProjectsDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error: cannot determine home directory: %v\n", err)
+		os.Exit(1)
+	}
+	return filepath.Join(home, ".claude", "projects")
+}
+
+// projectScope returns the project dirs matching the current directory and a
+// label describing the scope. Without matches, or with showAll, the filter is
+// nil and every project is in scope.
+func projectScope(claudeDir string, showAll bool) (filter []string, label string) {
+	if showAll {
+		return nil, "all projects"
+	}
+	matchingDirs := session.ProjectDirsForCWD(claudeDir)
+	if len(matchingDirs) == 0 {
+		return nil, "all projects"
+	}
+	cwd, _ := os.Getwd()
+	return matchingDirs, cwd
+}
+
+// loadIndex returns the persistent session index, or an empty one that
+// replaces it when reindex is set.
+func loadIndex(reindex bool) *session.Cache {
+	if reindex {
+		return session.NewCache(session.DefaultCachePath())
+	}
+	return session.LoadCache(session.DefaultCachePath())
+}
+
+// findSessions scans sessions through index and saves what it learned. A
+// failure to save only costs speed on the next run, so it is a warning.
+// Interrupting shiplog stops the scan.
+func findSessions(claudeDir string, projectFilter []string, index *session.Cache) ([]session.SessionInfo, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	sessions, problems, err := session.FindSessions(ctx, claudeDir, projectFilter, index)
+	if err != nil {
+		return nil, err
+	}
+	reportScanProblems(problems)
+	if err := index.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "  Warning: cannot save session index: %v\n", err)
+	}
+	return sessions, nil
+}
+
+// maxScanProblems is how many problem files of each kind are listed before
+// the rest are only counted.
+const maxScanProblems = 5
+
+// reportScanProblems prints the directories and transcripts that could not
+// be scanned, then those that were listed although reading them stopped
+// partway.
+func reportScanProblems(problems []session.FileError) {
+	var skipped, partial []session.FileError
+	for _, p := range problems {
+		if p.Partial {
+			partial = append(partial, p)
+		} else {
+			skipped = append(skipped, p)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "  Skipped %s:\n", parser.Plural(len(skipped), "unreadable file", "unreadable files"))
+		printScanProblems(skipped)
+	}
+	if len(partial) > 0 {
+		fmt.Fprintf(os.Stderr, "  Warning: %s only read in part, later entries are missing:\n", parser.Plural(len(partial), "session", "sessions"))
+		printScanProblems(partial)
+	}
+}
+
+// printScanProblems prints up to maxScanProblems problems, then the number
+// left.
+func printScanProblems(problems []session.FileError) {
+	for i, p := range problems {
+		if i == maxScanProblems {
+			fmt.Fprintf(os.Stderr, "    ... and %d more\n", len(problems)-i)
+			break
+		}
+		fmt.Fprintf(os.Stderr, "    %v\n", p)
+	}
+}
+
+// warnPartialRead reports the files of a session that could only be read in
+// part, one per line, after ParseSession returned what it could.
+func warnPartialRead(what string, err error) {
+	fmt.Fprintf(os.Stderr, "  Warning: %s only read in part, some entries are missing:\n", what)
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintf(os.Stderr, "    %s\n", line)
+	}
+}
+
+// reportParseIssues prints a summary of malformed lines and unrecognised
+// entry or block types found while parsing a transcript.
+func reportParseIssues(t *parser.Transcript) {
+	if t.Malformed > 0 {
+		fmt.Printf("  Skipped %d malformed lines\n", t.Malformed)
+	}
+	if len(t.Mistyped) > 0 {
+		counts := make(map[string]int)
+		var order []string
+		for _, m := range t.Mistyped {
+			if counts[m.Field] == 0 {
+				order = append(order, m.Field)
+			}
+			counts[m.Field]++
+		}
+		fmt.Printf("  Fields of the wrong type (left empty, entries kept):\n")
+		for _, field := range order {
+			fmt.Printf("    - %s (%d)\n", field, counts[field])
+		}
+	}
+	if len(t.Unknown) == 0 {
+		return
+	}
+	counts := make(map[string]int)
+	var order []string
+	for _, u := range t.Unknown {
+		key := u.Kind + " type \"" + u.Type + "\""
+		if counts[key] == 0 {
+			order = append(order, key)
+		}
+		counts[key]++
+	}
+	fmt.Printf("  Unrecognised content (not rendered):\n")
+	for _, key := range order {
+		fmt.Printf("    - %s (%d)\n", key, counts[key])
+	}
+}
+
+// redactSession removes secrets from a session's messages and title.
+func redactSession(r *redact.Redactor, messages []parser.Message, meta *parser.SessionMeta) []parser.Message {
+	meta.Title = r.String(meta.Title)
+	return r.Messages(messages)
+}
+
+// anonymizeSession rewrites the identifying names in a session's messages,
+// metadata and project path.
+func anonymizeSession(a *redact.Anonymizer, messages []parser.Message, meta *parser.SessionMeta, project string) ([]parser.Message, string) {
+	meta.Title = a.String(meta.Title)
+	meta.CWD = a.String(meta.CWD)
+	return redact.Rewrite(messages, a.String), a.String(project)
+}
+
+// reportRedactions prints how many values each detector replaced.
+func reportRedactions(counts []redact.Count) {
+	if len(counts) == 0 {
+		fmt.Println("  Nothing to redact")
+		return
+	}
+	total := 0
+	parts := make([]string, len(counts))
+	for i, c := range counts {
+		total += c.Count
+		parts[i] = fmt.Sprintf("%s (%d)", c.Name, c.Count)
+	}
+	fmt.Printf("  Redacted %d values: %s\n", total, strings.Join(parts, ", "))
+}
+
 // listSessions prints a formatted table of sessions.
 func listSessions(sessions []session.SessionInfo) {
 	// Sessions are already sorted by timestamp descending from FindSessions
 	fmt.Println()
-	fmt.Printf("  %-4s %-25s %-10s %-40s %s\n", "#", "Title", "ID", "Project", "Date")
-	fmt.Printf("  %s %s %s %s %s\n",
+	fmt.Printf("  %-4s %-25s %-10s %-40s %5s  %s\n", "#", "Title", "ID", "Project", "Msgs", "Date")
+	fmt.Printf("  %s %s %s %s %s  %s\n",
 		strings.Repeat("\u2500", 4),
 		strings.Repeat("\u2500", 25),
 		strings.Repeat("\u2500", 10),
 		strings.Repeat("\u2500", 40),
+		strings.Repeat("\u2500", 5),
 		strings.Repeat("\u2500", 12),
 	)
 
@@ -191,7 +573,7 @@ func listSessions(sessions []session.SessionInfo) {
 			shortID = shortID[:8]
 		}
 		proj := truncate(s.Project, 39)
-		fmt.Printf("  %-4d %-25s %-10s %-40s %s\n", i+1, title, shortID, proj, ts)
+		fmt.Printf("  %-4d %-25s %-10s %-40s %5d  %s\n", i+1, title, shortID, proj, s.UserCount+s.ReplyCount, ts)
 	}
 
 	fmt.Printf("\n  Total: %d sessions\n\n", len(sessions))
@@ -199,8 +581,9 @@ func listSessions(sessions []session.SessionInfo) {
 
 // truncate returns s truncated to maxLen characters.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	r := []rune(s)
+	if len(r) <= maxLen {
 		return s
 	}
-	return s[:maxLen]
+	return string(r[:maxLen])
 }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/HabibPro1999/shiplog/internal/parser"
+	"github.com/HabibPro1999/shiplog/internal/pricing"
+	"github.com/HabibPro1999/shiplog/internal/redact"
+	"github.com/HabibPro1999/shiplog/internal/render"
+	"github.com/HabibPro1999/shiplog/internal/search"
+	"github.com/HabibPro1999/shiplog/internal/stats"
+	pflag "github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+// runSearch implements `shiplog search`: sessions ranked by how well their
+// conversation text matches the terms.
+func runSearch(args []string) {
+	var (
+		showAll   bool
+		tools     bool
+		limit     int
+		exportN   int
+		output    string
+		format    string
+		thinking  string
+		prices    string
+		inline    bool
+		theme     string
+		tmplDir   string
+		redactOn  bool
+		redactCfg string
+		anonymize bool
+		anonCfg   string
+		reindex   bool
+	)
+
+	fs := pflag.NewFlagSet("search", pflag.ExitOnError)
+	fs.BoolVarP(&showAll, "all", "a", false, "Search all projects (ignore project context)")
+	fs.BoolVar(&tools, "tools", false, "Also search tool inputs, tool results and subagents")
+	fs.IntVarP(&limit, "limit", "n", 10, "Number of hits to show")
+	fs.IntVar(&exportN, "export", 0, "Export the Nth hit instead of listing hits")
+	fs.StringVarP(&output, "output", "o", "", "Output file path for --export")
+	fs.StringVar(&format, "format", render.FormatHTML, "Export format for --export: html, md or json")
+	fs.StringVar(&thinking, "thinking", render.ThinkingHide, "Thinking blocks for --export: hide, collapsed or show")
+	fs.StringVar(&prices, "pricing", "", "Pricing table JSON for --export (default: "+pricing.DefaultPath()+" if present)")
+	fs.BoolVar(&inline, "inline-images", false, "Markdown for --export: embed images as data URIs instead of sibling files")
+	fs.StringVar(&theme, "theme", render.ThemeAuto, "HTML theme for --export: "+strings.Join(render.Themes(), ", "))
+	fs.StringVar(&tmplDir, "template", "", "HTML template directory for --export")
+	fs.BoolVar(&redactOn, "redact", false, "Replace secrets and email addresses with placeholders in --export")
+	fs.StringVar(&redactCfg, "redact-config", "", "Redaction patterns JSON (default: "+redact.DefaultPath()+" if present)")
+	fs.BoolVar(&anonymize, "anonymize", false, "Replace home directory, user name, host name and internal names in --export")
+	fs.StringVar(&anonCfg, "anonymize-config", "", "Internal names JSON (default: "+redact.AnonymizePath()+" if present)")
+	fs.BoolVar(&reindex, "reindex", false, "Rebuild the session index instead of reusing cached headers")
+	fs.Parse(args)
+
+	q := search.NewQuery(fs.Args(), tools)
+	if q.Empty() {
+		fmt.Fprintln(os.Stderr, "  Usage: shiplog search [flags] <terms>")
+		os.Exit(1)
+	}
+	switch format {
+	case render.FormatHTML, render.FormatMarkdown, render.FormatJSON:
+	default:
+		fmt.Fprintf(os.Stderr, "  Error: invalid --format value %q (want html, md or json)\n", format)
+		os.Exit(1)
+	}
+	switch thinking {
+	case render.ThinkingHide, render.ThinkingCollapsed, render.ThinkingShow:
+	default:
+		fmt.Fprintf(os.Stderr, "  Error: invalid --thinking value %q (want hide, collapsed or show)\n", thinking)
+		os.Exit(1)
+	}
+	checkTheme(theme, tmplDir)
+	redactor := loadRedactor(redactOn, redactCfg)
+	anonymizer := loadAnonymizer(anonymize, anonCfg)
+
+	claudeDir := claudeProjectsDir()
+	projectFilter, scopeLabel := projectScope(claudeDir, showAll)
+
+	fmt.Printf("  Scanning sessions (%s)...\n", scopeLabel)
+	sessions, err := findSessions(claudeDir, projectFilter, loadIndex(reindex))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error scanning sessions: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("  Searching %d sessions for %s...\n", len(sessions), strings.Join(quoteAll(q.Terms), ", "))
+
+	// Sessions are parsed in parallel; results keep the scan order.
+	results := make([]*search.Hit, len(sessions))
+	errs := make([]error, len(sessions))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, s := range sessions {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			results[i], errs[i] = search.Session(s, q)
+		}()
+	}
+	wg.Wait()
+
+	var hits []*search.Hit
+	for i, h := range results {
+		if errs[i] != nil {
+			fmt.Fprintf(os.Stderr, "  Skipping %s: %v\n", sessions[i].SessionID, errs[i])
+			continue
+		}
+		if h != nil {
+			hits = append(hits, h)
+		}
+	}
+	search.Rank(hits, len(sessions))
+
+	if len(hits) == 0 {
+		fmt.Println("  No matches.")
+		if projectFilter != nil {
+			fmt.Println("  Use -a to search all projects.")
+		}
+		os.Exit(1)
+	}
+
+	if exportN != 0 {
+		if exportN < 1 || exportN > len(hits) {
+			fmt.Fprintf(os.Stderr, "  Error: --export %d out of range (%d hits)\n", exportN, len(hits))
+			os.Exit(1)
+		}
+		exportMatch(hits[exportN-1].Session, exportConfig{
+			output:     output,
+			thinking:   thinking,
+			branch:     parser.BranchLatest,
+			prices:     prices,
+			format:     format,
+			inline:     inline,
+			theme:      theme,
+			tmplDir:    tmplDir,
+			redactor:   redactor,
+			anonymizer: anonymizer,
+		})
+		return
+	}
+
+	printHits(hits, q, limit)
+}
+
+// printHits prints the first limit hits with their snippets, highlighting
+// the terms when stdout is a terminal.
+func printHits(hits []*search.Hit, q search.Query, limit int) {
+	mark := func(s string) string { return s }
+	if isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" {
+		mark = func(s string) string { return "\x1b[1;33m" + s + "\x1b[0m" }
+	}
+
+	fmt.Println()
+	for i, h := range hits {
+		if limit > 0 && i == limit {
+			break
+		}
+		s := h.Session
+		shortID := s.SessionID
+		if len(shortID) > 8 {
+			shortID = shortID[:8]
+		}
+		when := ""
+		if t := stats.ParseTime(h.Timestamp); !t.IsZero() {
+			when = t.Local().Format("Jan 02, 2006 15:04") + " · "
+		}
+		fmt.Printf("  %2d. %s  %s  %s\n", i+1, s.Title, shortID, s.Project)
+		fmt.Printf("      %s%s · %s\n", when, h.Role, parser.Plural(h.Count, "match", "matches"))
+		fmt.Printf("      %s\n\n", q.Highlight(h.Snippet, mark))
+	}
+
+	shown := len(hits)
+	if limit > 0 && shown > limit {
+		shown = limit
+	}
+	fmt.Printf("  %d of %d matching sessions. Export one with --export N.\n\n", shown, len(hits))
+}
+
+// quoteAll returns each string of ss in double quotes.
+func quoteAll(ss []string) []string {
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[i] = fmt.Sprintf("%q", s)
+	}
+	return out
+}
+
+// isTerminal reports whether f is a terminal, as picker.Available does.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HabibPro1999/shiplog/internal/pricing"
+	"github.com/HabibPro1999/shiplog/internal/render"
+	"github.com/HabibPro1999/shiplog/internal/session"
+	"github.com/HabibPro1999/shiplog/internal/stats"
+	pflag "github.com/spf13/pflag"
+)
+
+// runStats implements `shiplog stats`: usage aggregated across sessions.
+func runStats(args []string) {
+	var (
+		showAll bool
+		by      string
+		format  string
+		since   string
+		until   string
+		prices  string
+		reindex bool
+	)
+
+	fs := pflag.NewFlagSet("stats", pflag.ExitOnError)
+	fs.BoolVarP(&showAll, "all", "a", false, "Include all projects (ignore project context)")
+	fs.StringVar(&by, "by", stats.ByProject, "Group by project, model, week or day")
+	fs.StringVar(&format, "format", "table", "Output format: table, json or csv")
+	fs.StringVar(&since, "since", "", "Only sessions starting on or after this date (YYYY-MM-DD)")
+	fs.StringVar(&until, "until", "", "Only sessions starting on or before this date (YYYY-MM-DD)")
+	fs.StringVar(&prices, "pricing", "", "Pricing table JSON (default: "+pricing.DefaultPath()+" if present)")
+	fs.BoolVar(&reindex, "reindex", false, "Rebuild the session index instead of reusing cached headers")
+	fs.Parse(args)
+
+	switch by {
+	case stats.ByProject, stats.ByModel, stats.ByWeek, stats.ByDay:
+	default:
+		fmt.Fprintf(os.Stderr, "  Error: invalid --by value %q (want project, model, week or day)\n", by)
+		os.Exit(1)
+	}
+	switch format {
+	case "table", "json", "csv":
+	default:
+		fmt.Fprintf(os.Stderr, "  Error: invalid --format value %q (want table, json or csv)\n", format)
+		os.Exit(1)
+	}
+	from, err := parseDay(since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error: invalid --since date: %v\n", err)
+		os.Exit(1)
+	}
+	to, err := parseDay(until)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error: invalid --until date: %v\n", err)
+		os.Exit(1)
+	}
+	priceTable, err := pricing.Load(prices)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error loading pricing: %v\n", err)
+		os.Exit(1)
+	}
+
+	claudeDir := claudeProjectsDir()
+	projectFilter, scopeLabel := projectScope(claudeDir, showAll)
+
+	// Progress goes to stderr so JSON and CSV output can be piped.
+	fmt.Fprintf(os.Stderr, "  Scanning sessions (%s)...\n", scopeLabel)
+	sessions, err := findSessions(claudeDir, projectFilter, loadIndex(reindex))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error scanning sessions: %v\n", err)
+		os.Exit(1)
+	}
+
+	var selected []session.SessionInfo
+	for _, s := range sessions {
+		if withinDays(s, from, to) {
+			selected = append(selected, s)
+		}
+	}
+	// Sessions are parsed in parallel; results keep the scan order.
+	results := make([]stats.SessionStats, len(selected))
+	errs := make([]error, len(selected))
+	session.Parallel(context.Background(), len(selected), func(i int) {
+		results[i], errs[i] = stats.Collect(selected[i], priceTable)
+	})
+	var all []stats.SessionStats
+	for i, st := range results {
+		if errs[i] != nil {
+			fmt.Fprintf(os.Stderr, "  Skipping %s: %v\n", selected[i].SessionID, errs[i])
+			continue
+		}
+		all = append(all, st)
+	}
+	fmt.Fprintf(os.Stderr, "  %d sessions\n", len(all))
+
+	groups, err := stats.Aggregate(all, by)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  Error: %v\n", err)
+		os.Exit(1)
+	}
+	total := stats.Total(all, groups)
+
+	switch format {
+	case "json":
+		writeStatsJSON(groups, total, by)
+	case "csv":
+		writeStatsCSV(groups, total, by)
+	default:
+		printStatsTable(groups, total, by)
+	}
+}
+
+// parseDay parses a YYYY-MM-DD date in local time. An empty string yields
+// the zero time.
+func parseDay(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+// withinDays reports whether a session started between the days from and to,
+// inclusive. A zero bound is open; sessions without a start time only pass
+// when both bounds are open.
+func withinDays(s session.SessionInfo, from, to time.Time) bool {
+	start := stats.ParseTime(s.Timestamp)
+	if !from.IsZero() && (start.IsZero() || start.Before(from)) {
+		return false
+	}
+	if !to.IsZero() && (start.IsZero() || !start.Before(to.AddDate(0, 0, 1))) {
+		return false
+	}
+	return true
+}
+
+// printStatsTable prints groups as a terminal table followed by the most
+// used tools.
+func printStatsTable(groups []stats.Group, total stats.Group, by string) {
+	fmt.Println()
+	fmt.Printf("  %-32s %8s %9s %8s %8s %10s %10s %10s\n",
+		strings.ToUpper(by[:1])+by[1:], "Sessions", "Sess/day", "Prompts", "Replies", "Tool calls", "Tokens", "Cost")
+	rule := fmt.Sprintf("  %s %s %s %s %s %s %s %s",
+		strings.Repeat("─", 32),
+		strings.Repeat("─", 8),
+		strings.Repeat("─", 9),
+		strings.Repeat("─", 8),
+		strings.Repeat("─", 8),
+		strings.Repeat("─", 10),
+		strings.Repeat("─", 10),
+		strings.Repeat("─", 10),
+	)
+	fmt.Println(rule)
+	for _, g := range groups {
+		printStatsRow(g)
+	}
+	fmt.Println(rule)
+	printStatsRow(total)
+	if total.Unpriced {
+		fmt.Println("  * includes models without a known price (see --pricing)")
+	}
+
+	if tools := stats.TopTools(total.ToolCalls); len(tools) > 0 {
+		fmt.Println()
+		fmt.Printf("  %-32s %8s\n", "Tool", "Calls")
+		fmt.Printf("  %s %s\n", strings.Repeat("─", 32), strings.Repeat("─", 8))
+		for i, t := range tools {
+			if i == 15 {
+				fmt.Printf("  ... and %d more\n", len(tools)-i)
+				break
+			}
+			fmt.Printf("  %-32s %8d\n", truncate(t.Tool, 32), t.Calls)
+		}
+	}
+	fmt.Println()
+}
+
+// printStatsRow prints one row of the stats table.
+func printStatsRow(g stats.Group) {
+	cost := fmt.Sprintf("$%.2f", g.Cost)
+	if g.Unpriced {
+		cost += "*"
+	}
+	fmt.Printf("  %-32s %8d %9.1f %8d %8d %10d %10s %10s\n",
+		truncate(g.Key, 32), g.Sessions, g.SessionsPerDay(), g.Prompts, g.Replies,
+		g.ToolCallTotal(), render.FormatTokens(g.Tokens.Total), cost)
+}
+
+// writeStatsJSON writes groups and their total as a JSON document.
+func writeStatsJSON(groups []stats.Group, total stats.Group, by string) {
+	doc := struct {
+		GroupBy string        `json:"group_by"`
+		Groups  []stats.Group `json:"groups"`
+		Total   stats.Group   `json:"total"`
+	}{by, groups, total}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		fmt.Fprintf(os.Stderr, "  Error writing JSON: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// writeStatsCSV writes one row per group, with a column per tool, and a
+// last row with the total, as in the table.
+func writeStatsCSV(groups []stats.Group, total stats.Group, by string) {
+	var tools []string
+	for _, t := range stats.TopTools(total.ToolCalls) {
+		tools = append(tools, t.Tool)
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	header := []string{by, "sessions", "active_days", "prompts", "replies", "tool_calls",
+		"input_tokens", "output_tokens", "cache_creation_tokens", "cache_read_tokens",
+		"total_tokens", "estimated_cost_usd"}
+	for _, t := range tools {
+		header = append(header, "tool:"+t)
+	}
+	w.Write(header)
+	writeRow := func(g stats.Group) {
+		row := []string{
+			g.Key,
+			strconv.Itoa(g.Sessions),
+			strconv.Itoa(g.Days),
+			strconv.Itoa(g.Prompts),
+			strconv.Itoa(g.Replies),
+			strconv.Itoa(g.ToolCallTotal()),
+			strconv.Itoa(g.Tokens.Input),
+			strconv.Itoa(g.Tokens.Output),
+			strconv.Itoa(g.Tokens.CacheCreation),
+			strconv.Itoa(g.Tokens.CacheRead),
+			strconv.Itoa(g.Tokens.Total),
+			strconv.FormatFloat(g.Cost, 'f', 4, 64),
+		}
+		for _, t := range tools {
+			row = append(row, strconv.Itoa(g.ToolCalls[t]))
+		}
+		w.Write(row)
+	}
+	for _, g := range groups {
+		writeRow(g)
+	}
+	writeRow(total)
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fmt.Fprintf(os.Stderr, "  Error writing CSV: %v\n", err)
+		os.Exit(1)
+	}
+}